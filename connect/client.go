@@ -0,0 +1,543 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gopkg.in/resty.v1"
+)
+
+const (
+	connectorStateRunning = "RUNNING"
+	connectorStatePaused  = "PAUSED"
+	connectorStateStopped = "STOPPED"
+)
+
+// connectorStateActions maps a desired connector state to the Connect REST
+// action that moves a connector into it.
+var connectorStateActions = map[string]string{
+	connectorStateRunning: "resume",
+	connectorStatePaused:  "pause",
+	connectorStateStopped: "stop",
+}
+
+// connectorInfo is the response of the connector endpoints.
+type connectorInfo struct {
+	Code   int                    `json:"-"`
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+	Tasks  []taskID               `json:"tasks"`
+	Type   string                 `json:"type"`
+}
+
+type taskID struct {
+	Connector string `json:"connector"`
+	Task      int    `json:"task"`
+}
+
+// createConnectorRequest is the body of the create connector endpoint.
+type createConnectorRequest struct {
+	Name         string                 `json:"name"`
+	Config       map[string]interface{} `json:"config"`
+	InitialState string                 `json:"initial_state,omitempty"`
+}
+
+// connectorStatus is the response of the connector status endpoint.
+type connectorStatus struct {
+	Code      int             `json:"-"`
+	Name      string          `json:"name"`
+	Connector connectorWorker `json:"connector"`
+	Tasks     []taskStatus    `json:"tasks"`
+	Type      string          `json:"type"`
+}
+
+// connectorWorker is the state of the connector instance itself and the
+// worker it is assigned to.
+type connectorWorker struct {
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+type taskStatus struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+// configValidation is the response of the connector plugin config validation
+// endpoint.
+type configValidation struct {
+	Name       string       `json:"name"`
+	ErrorCount int          `json:"error_count"`
+	Configs    []configInfo `json:"configs"`
+}
+
+// configInfo pairs the definition of a single config key with the result of
+// validating the submitted value against it.
+type configInfo struct {
+	Definition configDefinition `json:"definition"`
+	Value      configValue      `json:"value"`
+}
+
+type configDefinition struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+type configValue struct {
+	Name   string   `json:"name"`
+	Value  *string  `json:"value"`
+	Errors []string `json:"errors"`
+}
+
+// connectorOffsets is the body of the connector offsets endpoints.
+type connectorOffsets struct {
+	Offsets []connectorOffset `json:"offsets"`
+}
+
+// connectorOffset is the position of a connector in one source partition, or
+// in one topic partition for a sink. A nil Offset resets the partition.
+type connectorOffset struct {
+	Partition map[string]interface{} `json:"partition"`
+	Offset    map[string]interface{} `json:"offset"`
+}
+
+// serverInfo is the response of the Connect root endpoint.
+type serverInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	KafkaClusterID string `json:"kafka_cluster_id"`
+}
+
+// client is the meta value handed to the resources. Every provider instance
+// has its own client and HTTP transport, so aliased providers talking to
+// clusters with different TLS settings do not interfere with each other.
+type client struct {
+	rest      *resty.Client
+	retry     retryPolicy
+	transport *http.Transport
+	// roundTripper is transport wrapped by the authentication layers.
+	roundTripper http.RoundTripper
+	// failover is set when the client spreads requests over several
+	// workers.
+	failover       *failoverTransport
+	requestTimeout time.Duration
+}
+
+func newClient(url string) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	return &client{
+		rest: resty.New().
+			SetTransport(transport).
+			SetHostURL(url).
+			SetHeader("Accept", "application/json").
+			SetTimeout(10 * time.Second),
+		retry:          defaultRetryPolicy,
+		transport:      transport,
+		roundTripper:   transport,
+		requestTimeout: 10 * time.Second,
+	}
+}
+
+// wrapTransport adds a layer around the round tripper used for every
+// request. Layers added later see the request first.
+func (c *client) wrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.roundTripper = wrap(c.roundTripper)
+	c.rest.SetTransport(c.roundTripper)
+}
+
+// setRequestTimeout bounds each HTTP request, including reading the
+// response body. With several workers the timeout applies to each of them,
+// and a request may take that long for every worker it tries.
+func (c *client) setRequestTimeout(timeout time.Duration) {
+	c.requestTimeout = timeout
+	c.applyRequestTimeout()
+}
+
+func (c *client) applyRequestTimeout() {
+	if c.failover == nil {
+		c.rest.SetTimeout(c.requestTimeout)
+		return
+	}
+	c.failover.attemptTimeout = c.requestTimeout
+	c.rest.SetTimeout(c.requestTimeout * time.Duration(len(c.failover.workers)))
+}
+
+// setProxy replaces the function choosing the proxy for each request.
+func (c *client) setProxy(proxy func(*http.Request) (*url.URL, error)) {
+	c.transport.Proxy = proxy
+}
+
+// setTLSConfig replaces the TLS settings used to reach Connect.
+func (c *client) setTLSConfig(config *tls.Config) {
+	c.transport.TLSClientConfig = config
+}
+
+// setWorkers spreads requests over the workers of one Connect cluster; see
+// failoverTransport. The first worker is the one the client was created for.
+func (c *client) setWorkers(workers []string) {
+	c.wrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		c.failover = newFailoverTransport(workers, base)
+		return c.failover
+	})
+	c.applyRequestTimeout()
+}
+
+// setTokenSource authenticates every request with a bearer token from the
+// source.
+func (c *client) setTokenSource(source oauth2.TokenSource) {
+	c.wrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return &oauth2.Transport{Source: source, Base: base}
+	})
+}
+
+// setCredentialHelper authenticates every request with the credentials
+// printed by the helper command; see credentialHelperTransport.
+func (c *client) setCredentialHelper(command []string) {
+	c.wrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return &credentialHelperTransport{command: command, base: base}
+	})
+}
+
+func (c *client) setBasicAuth(username string, password string) {
+	c.rest.SetBasicAuth(username, password)
+}
+
+func (c *client) setHeader(name string, value string) {
+	c.rest.SetHeader(name, value)
+}
+
+// serverInfo returns the version of the Connect worker.
+func (c *client) serverInfo(ctx context.Context) (serverInfo, error) {
+	result := serverInfo{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get("/")
+	if err != nil {
+		return serverInfo{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return serverInfo{}, responseError(resp, "get server info")
+	}
+
+	return result, nil
+}
+
+// getConnector returns the connector and its config. A missing connector is
+// an error for which isNotFoundError holds.
+func (c *client) getConnector(ctx context.Context, name string) (connectorInfo, error) {
+	result := connectorInfo{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		SetResult(&result).
+		Get("connectors/{name}")
+	if err != nil {
+		return connectorInfo{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return connectorInfo{}, responseError(resp, "get connector %s", name)
+	}
+
+	result.Code = resp.StatusCode()
+	return result, nil
+}
+
+// createConnector creates the connector and waits until every worker it is
+// asked about knows of it. A non-empty initialState, which requires Kafka 3.7
+// or later, creates the connector PAUSED or STOPPED instead of RUNNING.
+func (c *client) createConnector(ctx context.Context, name string, config map[string]interface{}, initialState string, timeout time.Duration) (connectorInfo, error) {
+	result := connectorInfo{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetBody(createConnectorRequest{Name: name, Config: config, InitialState: initialState}).
+		SetResult(&result).
+		Post("connectors")
+	if err != nil {
+		return connectorInfo{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return connectorInfo{}, responseError(resp, "create connector %s", name)
+	}
+	result.Code = resp.StatusCode()
+
+	err = pollUntil(ctx, func() (bool, error) {
+		conn, err := c.getConnector(ctx, name)
+		return err == nil && conn.Code == http.StatusOK, nil
+	}, timeout)
+	if err != nil {
+		return result, fmt.Errorf("create connector %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// updateConnector replaces the config of the connector and waits until
+// Connect serves the new config. The values of the sensitive keys are not
+// compared; see configApplied.
+func (c *client) updateConnector(ctx context.Context, name string, config map[string]interface{}, sensitiveKeys []string, timeout time.Duration) (connectorInfo, error) {
+	result := connectorInfo{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		SetBody(config).
+		SetResult(&result).
+		Put("connectors/{name}/config")
+	if err != nil {
+		return connectorInfo{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return connectorInfo{}, responseError(resp, "update connector %s", name)
+	}
+	result.Code = resp.StatusCode()
+
+	err = pollUntil(ctx, func() (bool, error) {
+		conn, err := c.getConnector(ctx, name)
+		return err == nil && conn.Code == http.StatusOK && configApplied(conn.Config, config, sensitiveKeys), nil
+	}, timeout)
+	if err != nil {
+		return result, fmt.Errorf("update connector %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// configApplied reports whether the config Connect serves reflects the
+// submitted one. Keys Connect added are ignored and values are compared as
+// they are in plans. Sensitive values, which Connect may mask or return as
+// ConfigProvider references, are only checked for presence.
+func configApplied(remote map[string]interface{}, submitted map[string]interface{}, sensitiveKeys []string) bool {
+	sensitive := make(map[string]bool, len(sensitiveKeys))
+	for _, k := range sensitiveKeys {
+		sensitive[k] = true
+	}
+
+	for k, v := range submitted {
+		r, ok := remote[k]
+		if !ok {
+			return false
+		}
+		rv := fmt.Sprint(r)
+		if sensitive[k] || rv == maskedSensitiveValue || configProviderPlaceholder.MatchString(rv) {
+			continue
+		}
+		if !configValuesEquivalent(k, fmt.Sprint(v), rv) {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteConnector deletes the connector and waits until Connect no longer
+// reports it.
+func (c *client) deleteConnector(ctx context.Context, name string, timeout time.Duration) error {
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		Delete("connectors/{name}")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 400 {
+		return responseError(resp, "delete connector %s", name)
+	}
+
+	err = pollUntil(ctx, func() (bool, error) {
+		_, err := c.getConnector(ctx, name)
+		return isNotFoundError(err), nil
+	}, timeout)
+	if err != nil {
+		return fmt.Errorf("delete connector %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// changeConnectorState asks Connect to move the connector into the given
+// state. The transition is asynchronous; see waitForConnectorState.
+// Stopping a connector requires Kafka 3.5 or later.
+func (c *client) changeConnectorState(ctx context.Context, name string, state string) error {
+	action, ok := connectorStateActions[state]
+	if !ok {
+		return fmt.Errorf("unknown connector state %q", state)
+	}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		Put("connectors/{name}/" + action)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 400 {
+		return responseError(resp, "%s connector %s", action, name)
+	}
+
+	return nil
+}
+
+// getConnectorStatus returns the state of the connector and its tasks. Unlike
+// the go-kafka-connect equivalent it also reports the connector type. A
+// missing connector is an error for which isNotFoundError holds.
+func (c *client) getConnectorStatus(ctx context.Context, name string) (connectorStatus, error) {
+	result := connectorStatus{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		SetResult(&result).
+		Get("connectors/{name}/status")
+	if err != nil {
+		return connectorStatus{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return connectorStatus{}, responseError(resp, "get connector status %s", name)
+	}
+
+	result.Code = resp.StatusCode()
+	return result, nil
+}
+
+// getConnectorOffsets returns the committed offsets of the connector.
+// Requires Kafka 3.5 or later.
+func (c *client) getConnectorOffsets(ctx context.Context, name string) ([]connectorOffset, error) {
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		Get("connectors/{name}/offsets")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() >= 400 {
+		return nil, responseError(resp, "get offsets of connector %s", name)
+	}
+
+	// decoded by hand to keep offsets beyond the precision of a float64
+	result := connectorOffsets{}
+	dec := json.NewDecoder(bytes.NewReader(resp.Body()))
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("get offsets of connector %s: %w", name, err)
+	}
+	return result.Offsets, nil
+}
+
+// alterConnectorOffsets overwrites the offsets of the given partitions. The
+// connector must be STOPPED. Requires Kafka 3.6 or later.
+func (c *client) alterConnectorOffsets(ctx context.Context, name string, offsets []connectorOffset) error {
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		SetBody(connectorOffsets{Offsets: offsets}).
+		Patch("connectors/{name}/offsets")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 400 {
+		return responseError(resp, "alter offsets of connector %s", name)
+	}
+
+	return nil
+}
+
+// resetConnectorOffsets deletes all offsets of the connector. The connector
+// must be STOPPED. Requires Kafka 3.6 or later.
+func (c *client) resetConnectorOffsets(ctx context.Context, name string) error {
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"name": name}).
+		Delete("connectors/{name}/offsets")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 400 {
+		return responseError(resp, "reset offsets of connector %s", name)
+	}
+
+	return nil
+}
+
+// validateConnectorConfig validates the config against the definitions of the
+// given connector plugin without creating anything.
+func (c *client) validateConnectorConfig(ctx context.Context, class string, config map[string]interface{}) (configValidation, error) {
+	result := configValidation{}
+
+	resp, err := c.rest.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"class": class}).
+		SetBody(config).
+		SetResult(&result).
+		Put("connector-plugins/{class}/config/validate")
+	if err != nil {
+		return configValidation{}, err
+	}
+	if resp.StatusCode() >= 400 {
+		return configValidation{}, responseError(resp, "validate config for %s", class)
+	}
+
+	return result, nil
+}
+
+var errPollTimeout = errors.New("timed out waiting for Kafka Connect")
+
+// pollUntil calls done every second until it reports true, returns an error,
+// or the timeout expires or the context is done.
+func pollUntil(ctx context.Context, done func() (bool, error), timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errPollTimeout
+		}
+		if err := sleepContext(ctx, time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext sleeps for the duration, returning the error of the context
+// early if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// desiredStateFromStatus maps the state reported by the status endpoint to
+// one of the states a user can request. Transient and failure states such
+// as UNASSIGNED or FAILED belong to a connector that is meant to be running.
+func desiredStateFromStatus(state string) string {
+	switch state {
+	case connectorStatePaused, connectorStateStopped:
+		return state
+	default:
+		return connectorStateRunning
+	}
+}
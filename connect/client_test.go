@@ -0,0 +1,89 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangeConnectorState(t *testing.T) {
+	for state, action := range connectorStateActions {
+		t.Run(state, func(t *testing.T) {
+			var gotMethod, gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				gotMethod, gotPath = req.Method, req.URL.Path
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			if err := newClient(srv.URL).changeConnectorState(context.Background(), "sqlite-sink", state); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if gotMethod != http.MethodPut || gotPath != "/connectors/sqlite-sink/"+action {
+				t.Errorf("expected PUT /connectors/sqlite-sink/%s, got %s %s", action, gotMethod, gotPath)
+			}
+		})
+	}
+
+	t.Run("unknown state", func(t *testing.T) {
+		if err := newClient("http://localhost:0").changeConnectorState(context.Background(), "sqlite-sink", "FAILED"); err == nil {
+			t.Errorf("expected an error for an unknown state")
+		}
+	})
+}
+
+func TestDesiredStateFromStatus(t *testing.T) {
+	cases := map[string]string{
+		"RUNNING":    connectorStateRunning,
+		"PAUSED":     connectorStatePaused,
+		"STOPPED":    connectorStateStopped,
+		"FAILED":     connectorStateRunning,
+		"UNASSIGNED": connectorStateRunning,
+		"RESTARTING": connectorStateRunning,
+	}
+	for status, expected := range cases {
+		if got := desiredStateFromStatus(status); got != expected {
+			t.Errorf("desiredStateFromStatus(%q) = %q, expected %q", status, got, expected)
+		}
+	}
+}
+
+func TestUpdateConnectorNormalisedConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name":"sqlite-sink","config":{"name":"sqlite-sink","topics":"customers,orders","tasks.max":"1","connection.password":"[hidden]","connection.user":"${file:/opt/secrets:user}","errors.tolerance":"none"},"tasks":[]}`)
+	}))
+	defer srv.Close()
+
+	config := map[string]interface{}{
+		"name":                "sqlite-sink",
+		"topics":              "orders, customers",
+		"tasks.max":           "1.0",
+		"connection.password": "hunter2",
+		"connection.user":     "admin",
+	}
+	_, err := newClient(srv.URL).updateConnector(context.Background(), "sqlite-sink", config, []string{"connection.password"}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("expected the normalised config to confirm the update, got %v", err)
+	}
+}
+
+func TestConfigApplied(t *testing.T) {
+	submitted := map[string]interface{}{"tasks.max": "2", "connection.password": "hunter2"}
+	cases := map[string]struct {
+		remote   map[string]interface{}
+		expected bool
+	}{
+		"applied":        {map[string]interface{}{"tasks.max": "2", "connection.password": "other", "extra": "x"}, true},
+		"not yet":        {map[string]interface{}{"tasks.max": "1", "connection.password": "hunter2"}, false},
+		"missing secret": {map[string]interface{}{"tasks.max": "2"}, false},
+	}
+	for name, tt := range cases {
+		if got := configApplied(tt.remote, submitted, []string{"connection.password"}); got != tt.expected {
+			t.Errorf("%s: expected %t, got %t", name, tt.expected, got)
+		}
+	}
+}
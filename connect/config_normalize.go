@@ -0,0 +1,131 @@
+package connect
+
+import (
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// orderedListConfigKeys are comma separated config values whose order
+// matters, such as the chain of single message transforms.
+var orderedListConfigKeys = map[string]bool{
+	"transforms": true,
+	"predicates": true,
+}
+
+// isUnorderedListConfigKey reports whether the key holds a comma separated
+// list Connect treats as a set, such as the topics of a sink.
+func isUnorderedListConfigKey(key string) bool {
+	return key == "topics" ||
+		strings.HasSuffix(key, ".include.list") ||
+		strings.HasSuffix(key, ".exclude.list") ||
+		strings.HasSuffix(key, ".whitelist") ||
+		strings.HasSuffix(key, ".blacklist")
+}
+
+// configValuesEquivalent reports whether two values of the same config key
+// mean the same thing to Connect, ignoring surrounding whitespace, the
+// formatting of numbers and booleans, and the order of unordered lists.
+func configValuesEquivalent(key string, a string, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+
+	if isUnorderedListConfigKey(key) {
+		la, lb := splitConfigList(a), splitConfigList(b)
+		sort.Strings(la)
+		sort.Strings(lb)
+		return strings.Join(la, ",") == strings.Join(lb, ",")
+	}
+	if orderedListConfigKeys[key] {
+		return strings.Join(splitConfigList(a), ",") == strings.Join(splitConfigList(b), ",")
+	}
+
+	if ia, ok := parseConfigInteger(a); ok {
+		if ib, ok := parseConfigInteger(b); ok {
+			return ia.Cmp(ib) == 0
+		}
+	}
+
+	isBool := func(s string) bool { return strings.EqualFold(s, "true") || strings.EqualFold(s, "false") }
+	if isBool(a) && isBool(b) {
+		return strings.EqualFold(a, b)
+	}
+
+	return false
+}
+
+// configIntegerPattern matches decimal integers, optionally written with a
+// fraction of zeros such as 1.0. Other numbers, such as 1.10, may well be
+// version strings and are compared as written.
+var configIntegerPattern = regexp.MustCompile(`^([+-]?[0-9]+)(\.0+)?$`)
+
+// parseConfigInteger parses the value as an integer of any size, so that
+// large ids are compared exactly.
+func parseConfigInteger(s string) (*big.Int, bool) {
+	m := configIntegerPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	return new(big.Int).SetString(m[1], 10)
+}
+
+func splitConfigList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// suppressEquivalentConfigDiff is the DiffSuppressFunc of the config map. It
+// is called with keys of the form config.<key>.
+func suppressEquivalentConfigDiff(k, old, new string, d *schema.ResourceData) bool {
+	key := strings.TrimPrefix(k, "config.")
+	if key == "%" || old == "" || new == "" {
+		return false
+	}
+	return configValuesEquivalent(key, old, new)
+}
+
+// preferLocalConfigValues returns desired with each value that is equivalent
+// to the one in current replaced by the current one, so that a change in
+// formatting alone never shows up as a diff.
+func preferLocalConfigValues(desired map[string]interface{}, current map[string]interface{}) map[string]interface{} {
+	for k, v := range desired {
+		if cv, ok := current[k]; ok && configValuesEquivalent(k, cv.(string), v.(string)) {
+			desired[k] = cv
+		}
+	}
+	return desired
+}
+
+// reconcileRemoteConfig prepares the config read from Connect for state. Keys
+// Connect added that the user never declared are dropped, and values Connect
+// normalised keep the representation the user wrote. Without a local config,
+// as on import, the remote config is used as is.
+func reconcileRemoteConfig(remote map[string]interface{}, local map[string]interface{}) map[string]interface{} {
+	if len(local) == 0 {
+		return remote
+	}
+
+	reconciled := make(map[string]interface{}, len(local))
+	for k, v := range remote {
+		lv, ok := local[k]
+		if !ok {
+			continue
+		}
+		if s, isString := v.(string); isString && configValuesEquivalent(k, lv.(string), s) {
+			reconciled[k] = lv
+			continue
+		}
+		reconciled[k] = v
+	}
+	return reconciled
+}
@@ -0,0 +1,92 @@
+package connect
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestConfigValuesEquivalent(t *testing.T) {
+	cases := []struct {
+		key, a, b string
+		expected  bool
+	}{
+		{"tasks.max", "1", "1", true},
+		{"tasks.max", " 1 ", "1", true},
+		{"tasks.max", "1", "1.0", true},
+		{"tasks.max", "1", "2", false},
+		{"tasks.max", "+1", "01", true},
+		{"plugin.version", "1.10", "1.1", false},
+		{"snapshot.id", "12345678901234567890", "12345678901234567891", false},
+		{"snapshot.id", "12345678901234567890", "12345678901234567890.0", true},
+		{"mask", "0x10", "16", false},
+		{"threshold", "Inf", "+Inf", false},
+		{"auto.create", "true", "TRUE", true},
+		{"auto.create", "true", "false", false},
+		{"auto.create", "true", "1", false},
+		{"topics", "orders,customers", "customers, orders", true},
+		{"topics", "orders,customers", "orders", false},
+		{"table.include.list", "public.a,public.b", "public.b,public.a", true},
+		{"transforms", "unwrap, route", "unwrap,route", true},
+		{"transforms", "unwrap,route", "route,unwrap", false},
+		{"connection.url", "jdbc:sqlite:a.db", "jdbc:sqlite:b.db", false},
+	}
+	for _, c := range cases {
+		if got := configValuesEquivalent(c.key, c.a, c.b); got != c.expected {
+			t.Errorf("configValuesEquivalent(%q, %q, %q) = %t, expected %t", c.key, c.a, c.b, got, c.expected)
+		}
+	}
+}
+
+func TestReconcileRemoteConfig(t *testing.T) {
+	local := map[string]interface{}{
+		"topics":    "orders, customers",
+		"tasks.max": "1",
+	}
+	remote := map[string]interface{}{
+		"topics":           "customers,orders",
+		"tasks.max":        "2",
+		"errors.tolerance": "none",
+	}
+
+	expected := map[string]interface{}{
+		"topics":    "orders, customers",
+		"tasks.max": "2",
+	}
+	if got := reconcileRemoteConfig(remote, local); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if got := reconcileRemoteConfig(remote, nil); !reflect.DeepEqual(got, remote) {
+		t.Errorf("expected the remote config to be used as is on import, got %v", got)
+	}
+}
+
+func TestConnectorPlanSuppressesEquivalentConfig(t *testing.T) {
+	prior := testConnectorObject(map[string]cty.Value{
+		"id":   cty.StringVal("sqlite-sink"),
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"topics":    cty.StringVal("orders,customers"),
+			"tasks.max": cty.StringVal("1"),
+		}),
+		"config_sensitive": cty.MapValEmpty(cty.String),
+		"wait_for_running": cty.False,
+	})
+	config := testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"topics":    cty.StringVal("customers, orders"),
+			"tasks.max": cty.StringVal("1.0"),
+		}),
+	})
+
+	planned, diags := testPlanConnector(t, nil, prior, config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if !planned.GetAttr("config").RawEquals(prior.GetAttr("config")) {
+		t.Errorf("expected an empty plan, got config %#v", planned.GetAttr("config"))
+	}
+}
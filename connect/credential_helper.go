@@ -0,0 +1,129 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialHelperTimeout bounds a single run of the credential helper.
+const credentialHelperTimeout = 30 * time.Second
+
+// helperCredentials is the JSON object a credential helper prints on stdout.
+// It holds headers, basic auth credentials, or both.
+type helperCredentials struct {
+	Headers  map[string]string `json:"headers"`
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+}
+
+// credentialHelperTransport adds the credentials printed by an external
+// command to every request, in the manner of the Git and Docker credential
+// helpers. The credentials are cached until Connect answers 401, when the
+// helper is run again and the request retried once.
+type credentialHelperTransport struct {
+	command []string
+	base    http.RoundTripper
+
+	mu          sync.Mutex
+	credentials *helperCredentials
+}
+
+func (t *credentialHelperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds, err := t.currentCredentials(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(withHelperCredentials(req, creds))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body was consumed and cannot be sent again
+		return resp, nil
+	}
+
+	log.Printf("[INFO] Kafka Connect answered 401, running the credential helper again")
+	creds, err = t.currentCredentials(creds)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	retry := withHelperCredentials(req, creds)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// currentCredentials returns the cached credentials, running the helper if
+// there are none or if the cached ones are the rejected ones.
+func (t *credentialHelperTransport) currentCredentials(rejected *helperCredentials) (*helperCredentials, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.credentials != nil && t.credentials != rejected {
+		return t.credentials, nil
+	}
+
+	creds, err := runCredentialHelper(t.command)
+	if err != nil {
+		return nil, err
+	}
+	t.credentials = creds
+	return creds, nil
+}
+
+// withHelperCredentials returns a copy of the request with the credentials
+// applied, as a RoundTripper must not modify the request it is given.
+func withHelperCredentials(req *http.Request, creds *helperCredentials) *http.Request {
+	req = req.Clone(req.Context())
+	if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	for k, v := range creds.Headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+// runCredentialHelper runs the command and parses the credentials it prints.
+func runCredentialHelper(command []string) (*helperCredentials, error) {
+	if len(command) == 0 {
+		return nil, errors.New("credential_helper must name a command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %s: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	creds := &helperCredentials{}
+	if err := json.Unmarshal(stdout.Bytes(), creds); err != nil {
+		return nil, fmt.Errorf("credential helper %s printed invalid JSON: %w", command[0], err)
+	}
+	if len(creds.Headers) == 0 && creds.Username == "" {
+		return nil, fmt.Errorf("credential helper %s printed neither headers nor a username", command[0])
+	}
+	return creds, nil
+}
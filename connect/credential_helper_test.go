@@ -0,0 +1,90 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCredentialHelper writes a helper script that prints the given JSON
+// template, with %d replaced by the number of times it has been run.
+func testCredentialHelper(t *testing.T, template string) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "helper.sh")
+	counter := filepath.Join(dir, "runs")
+	content := fmt.Sprintf(`#!/bin/sh
+n=$(( $(cat %[1]s 2>/dev/null || echo 0) + 1 ))
+echo $n > %[1]s
+printf '%[2]s' $n
+`, counter, template)
+	if err := os.WriteFile(script, []byte(content), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return []string{"/bin/sh", script}
+}
+
+func TestCredentialHelperRefreshesOnUnauthorized(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"name":"jdbc","error_count":0,"configs":[]}`)
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL)
+	c.setCredentialHelper(testCredentialHelper(t, `{"headers":{"Authorization":"Bearer token-%d"}}`))
+
+	config := map[string]interface{}{"connector.class": "jdbc"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.validateConnectorConfig(context.Background(), "jdbc", config); err != nil {
+			t.Fatalf("expected the request to succeed with refreshed credentials, got %v", err)
+		}
+	}
+
+	expected := []string{"Bearer token-1", "Bearer token-2", "Bearer token-2"}
+	if fmt.Sprint(seen) != fmt.Sprint(expected) {
+		t.Errorf("expected requests with %v, got %v", expected, seen)
+	}
+}
+
+func TestCredentialHelperBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, pass, ok := req.BasicAuth(); !ok || user != "user-1" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"version":"3.7.0"}`)
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL)
+	c.setCredentialHelper(testCredentialHelper(t, `{"username":"user-%d","password":"secret"}`))
+	if _, err := c.serverInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCredentialHelperErrors(t *testing.T) {
+	cases := map[string][]string{
+		"failing command": {"/bin/sh", "-c", "echo denied >&2; exit 1"},
+		"invalid JSON":    {"/bin/sh", "-c", "echo not json"},
+		"no credentials":  {"/bin/sh", "-c", "echo {}"},
+	}
+	for name, command := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := runCredentialHelper(command); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package connect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"gopkg.in/resty.v1"
+)
+
+// apiError is an error response of the Connect REST API.
+type apiError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// ErrorCode and Message are the error_code and message of the body, which
+	// Connect sends for most errors. ErrorCode is usually the HTTP status.
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+	// action describes the request that failed, such as "get connector foo".
+	action string
+}
+
+func (e *apiError) Error() string {
+	if e.action == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.action, e.Message)
+}
+
+// responseError returns the apiError of a response with an error status. The
+// whole body is used as the message when it is not a Connect error object.
+func responseError(resp *resty.Response, format string, args ...interface{}) error {
+	err := &apiError{
+		StatusCode: resp.StatusCode(),
+		action:     fmt.Sprintf(format, args...),
+	}
+	if json.Unmarshal(resp.Body(), err) != nil || err.Message == "" {
+		err.Message = strings.TrimSpace(resp.String())
+	}
+	if err.Message == "" {
+		err.Message = resp.Status()
+	}
+	return err
+}
+
+// isRebalanceError reports whether the error is the 409 Connect answers with
+// while the group rebalances or a config change is in flight. Creating a
+// connector that already exists also answers 409, but will never succeed.
+func isRebalanceError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict &&
+		!strings.Contains(apiErr.Message, "already exists")
+}
+
+// isNotFoundError reports whether Connect answered that the resource does not
+// exist. A 404 without Connect's error body, as from a gateway or a url with
+// the wrong path, says nothing about the connector and is not treated as one.
+func isNotFoundError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound &&
+		apiErr.ErrorCode == http.StatusNotFound
+}
+
+// diagFromErr is diag.FromErr with a summary naming the failed request and
+// its HTTP status for errors returned by Connect, and the message Connect
+// gave as the detail.
+func diagFromErr(err error) diag.Diagnostics {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return diag.FromErr(err)
+	}
+
+	summary := fmt.Sprintf("Kafka Connect returned %d %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	if apiErr.action != "" {
+		summary = fmt.Sprintf("Failed to %s: Kafka Connect returned %d %s", apiErr.action, apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail:   err.Error(),
+	}}
+}
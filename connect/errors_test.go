@@ -0,0 +1,69 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseError(t *testing.T) {
+	cases := map[string]struct {
+		status    int
+		body      string
+		errorCode int
+		message   string
+	}{
+		"connect error": {
+			status:    http.StatusBadRequest,
+			body:      `{"error_code":400,"message":"Connector configuration is invalid"}`,
+			errorCode: 400,
+			message:   "Connector configuration is invalid",
+		},
+		"proxy error": {
+			status:  http.StatusBadGateway,
+			body:    "<html>Bad Gateway</html>\n",
+			message: "<html>Bad Gateway</html>",
+		},
+		"empty body": {
+			status:  http.StatusServiceUnavailable,
+			message: "503 Service Unavailable",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			_, err := newClient(srv.URL).getConnector(context.Background(), "orders")
+			apiErr, ok := err.(*apiError)
+			if !ok {
+				t.Fatalf("expected an *apiError, got %T: %v", err, err)
+			}
+			if apiErr.StatusCode != tc.status || apiErr.ErrorCode != tc.errorCode || apiErr.Message != tc.message {
+				t.Errorf("unexpected error %+v", apiErr)
+			}
+			if expected := "get connector orders: " + tc.message; err.Error() != expected {
+				t.Errorf("expected %q, got %q", expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestDiagFromErr(t *testing.T) {
+	err := &apiError{StatusCode: http.StatusBadRequest, ErrorCode: 400, Message: "Connector configuration is invalid", action: "create connector orders"}
+	diags := diagFromErr(fmt.Errorf("wrapped: %w", err))
+	if len(diags) != 1 {
+		t.Fatalf("expected a single diagnostic, got %v", diags)
+	}
+	if expected := "Failed to create connector orders: Kafka Connect returned 400 Bad Request"; diags[0].Summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, diags[0].Summary)
+	}
+	if expected := "wrapped: create connector orders: Connector configuration is invalid"; diags[0].Detail != expected {
+		t.Errorf("expected detail %q, got %q", expected, diags[0].Detail)
+	}
+}
@@ -0,0 +1,179 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// failoverTransport sends each request to the last worker that answered,
+// moving on to the next worker when one cannot be reached. A request that
+// changes nothing also moves on when the worker does not answer within
+// attemptTimeout or answers with a 5xx status; see shouldFailOver. Requests
+// are built against the first worker, whose scheme, host and path prefix are
+// swapped for those of the chosen one.
+type failoverTransport struct {
+	workers []*url.URL
+	base    http.RoundTripper
+	// attemptTimeout bounds the request to each worker, so that a worker
+	// that hangs leaves time to try the others. Zero means no limit.
+	attemptTimeout time.Duration
+
+	mu      sync.Mutex
+	current int
+}
+
+// newFailoverTransport returns a failoverTransport over the workers, which
+// must be valid URLs.
+func newFailoverTransport(workers []string, base http.RoundTripper) *failoverTransport {
+	t := &failoverTransport{base: base}
+	for _, w := range workers {
+		u, _ := url.Parse(w)
+		t.workers = append(t.workers, u)
+	}
+	return t
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	start := t.current
+	t.mu.Unlock()
+
+	var resp *http.Response
+	var err error
+	for i := range t.workers {
+		worker := (start + i) % len(t.workers)
+		retry := i > 0
+		if retry {
+			if req.Body != nil && req.GetBody == nil {
+				// the body was consumed and cannot be sent again
+				break
+			}
+			log.Printf("[WARN] Kafka Connect worker %s failed (%s), trying %s",
+				t.workers[(worker+len(t.workers)-1)%len(t.workers)].Host, failoverReason(resp, err), t.workers[worker].Host)
+			if resp != nil {
+				resp.Body.Close()
+			}
+		}
+
+		ctx, cancel := context.WithCancel(req.Context())
+		if t.attemptTimeout > 0 {
+			ctx, cancel = context.WithTimeout(req.Context(), t.attemptTimeout)
+		}
+		var attempt *http.Request
+		attempt, err = t.forWorker(req.WithContext(ctx), worker, retry)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp, err = t.base.RoundTrip(attempt)
+		if !shouldFailOver(req, resp, err) {
+			t.mu.Lock()
+			t.current = worker
+			t.mu.Unlock()
+			if resp != nil {
+				// the attempt must outlive RoundTrip until the body is read
+				resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			} else {
+				cancel()
+			}
+			return resp, err
+		}
+		cancel()
+
+		// later requests skip the failed worker, even if all of them fail
+		t.mu.Lock()
+		if t.current == worker {
+			t.current = (worker + 1) % len(t.workers)
+		}
+		t.mu.Unlock()
+	}
+
+	return resp, err
+}
+
+// cancelOnClose ends the context of a request when its response body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// forWorker returns a copy of the request aimed at the given worker, with a
+// fresh body if the request is being retried.
+func (t *failoverTransport) forWorker(req *http.Request, worker int, retry bool) (*http.Request, error) {
+	primary, target := t.workers[0], t.workers[worker]
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.Host = ""
+	out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" +
+		strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(primary.Path, "/")), "/")
+	out.URL.RawPath = ""
+
+	if retry && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = body
+	}
+	return out, nil
+}
+
+// shouldFailOver reports whether the request should be sent to the next
+// worker. A cancelled request never is. A request that changes the cluster is
+// only sent again when it never reached the worker: Connect often answers 500
+// "Request timed out" after the leader applied the request, and a worker that
+// hangs or drops the connection may have applied it too, so sending it again
+// would fail or apply it twice. Such errors are left to the retry policy.
+func shouldFailOver(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil && (isReadOnlyRequest(req) || requestNotSent(err))
+	}
+	return resp.StatusCode >= 500 && isReadOnlyRequest(req)
+}
+
+// requestNotSent reports whether the error shows that the request never
+// reached the worker, because no connection could be made.
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &dnsErr) ||
+		(errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// isReadOnlyRequest reports whether the request leaves the cluster
+// unchanged. Validating a config is a PUT, but changes nothing.
+func isReadOnlyRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPut:
+		return strings.HasSuffix(req.URL.Path, "/config/validate")
+	}
+	return false
+}
+
+func failoverReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
@@ -0,0 +1,161 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailoverTransport(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	rolling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer rolling.Close()
+
+	var requests []string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", req.Method, req.URL.Path, body))
+		fmt.Fprint(w, `{"name":"jdbc","error_count":0,"configs":[]}`)
+	}))
+	defer healthy.Close()
+
+	c := newClient(downURL)
+	c.setWorkers([]string{downURL, rolling.URL, healthy.URL + "/connect/"})
+
+	config := map[string]interface{}{"connector.class": "jdbc"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.validateConnectorConfig(context.Background(), "jdbc", config); err != nil {
+			t.Fatalf("expected the request to fail over to the healthy worker, got %v", err)
+		}
+	}
+
+	expected := `[PUT /connect/connector-plugins/jdbc/config/validate {"connector.class":"jdbc"} PUT /connect/connector-plugins/jdbc/config/validate {"connector.class":"jdbc"}]`
+	if got := fmt.Sprint(requests); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if current := c.roundTripper.(*failoverTransport).current; current != 2 {
+		t.Errorf("expected the healthy worker to be remembered, got worker %d", current)
+	}
+}
+
+func TestFailoverTransportAllWorkersFail(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error_code":500,"message":"Request timed out"}`)
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL)
+	c.setWorkers([]string{srv.URL, srv.URL + "/"})
+
+	if _, err := c.serverInfo(context.Background()); err == nil {
+		t.Error("expected the last server error to be returned")
+	}
+	if hits != 2 {
+		t.Errorf("expected each worker to be tried once, got %d requests", hits)
+	}
+}
+
+func TestFailoverTransportHungWorker(t *testing.T) {
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version":"3.7.0"}`)
+	}))
+	defer healthy.Close()
+
+	c := newClient(hung.URL)
+	c.setWorkers([]string{hung.URL, healthy.URL})
+	c.setRequestTimeout(200 * time.Millisecond)
+
+	info, err := c.serverInfo(context.Background())
+	if err != nil || info.Version != "3.7.0" {
+		t.Fatalf("expected the request to fail over from the hung worker, got %v %v", info, err)
+	}
+	if current := c.failover.current; current != 1 {
+		t.Errorf("expected the hung worker to be skipped, got worker %d", current)
+	}
+}
+
+func TestFailoverTransportServerErrorNotReplayed(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error_code":500,"message":"Request timed out"}`)
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL)
+	c.setWorkers([]string{srv.URL, srv.URL + "/"})
+
+	_, err := c.createConnector(context.Background(), "orders", map[string]interface{}{}, "", time.Second)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the server error to be returned, got %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("expected the create not to be sent to another worker, got %d requests", hits)
+	}
+}
+
+func TestFailoverTransportHungWorkerNotReplayed(t *testing.T) {
+	var hits int
+	var mu sync.Mutex
+	count := func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+	}
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		count(w, req)
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer hung.Close()
+	defer close(release)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		count(w, req)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer healthy.Close()
+
+	c := newClient(hung.URL)
+	c.setWorkers([]string{hung.URL, healthy.URL})
+	c.setRequestTimeout(200 * time.Millisecond)
+
+	if _, err := c.createConnector(context.Background(), "orders", map[string]interface{}{}, "", time.Second); err == nil {
+		t.Error("expected the timeout to be returned")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 1 {
+		t.Errorf("expected the create not to be sent to another worker, got %d requests", hits)
+	}
+}
@@ -0,0 +1,175 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// parseOffsets parses the JSON list of partition and offset pairs of the
+// offsets attribute. Numbers are kept exact, as offsets can exceed the
+// integers a float64 represents.
+func parseOffsets(s string) ([]connectorOffset, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+
+	var offsets []connectorOffset
+	if err := dec.Decode(&offsets); err != nil {
+		return nil, fmt.Errorf("offsets must be a JSON list of partition and offset objects: %w", err)
+	}
+	for i, o := range offsets {
+		if len(o.Partition) == 0 {
+			return nil, fmt.Errorf("offsets[%d] has no partition", i)
+		}
+	}
+	return offsets, nil
+}
+
+func validateOffsets(v interface{}, k string) ([]string, []error) {
+	if _, err := parseOffsets(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// offsetKey is the canonical JSON of a partition or offset, in which map keys
+// are sorted.
+func offsetKey(v map[string]interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// offsetsEquivalent reports whether both lists hold the same offset for the
+// same partitions, in any order.
+func offsetsEquivalent(a []connectorOffset, b []connectorOffset) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	canonical := func(offsets []connectorOffset) []string {
+		keys := make([]string, 0, len(offsets))
+		for _, o := range offsets {
+			keys = append(keys, offsetKey(o.Partition)+"="+offsetKey(o.Offset))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	ka, kb := canonical(a), canonical(b)
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// suppressEquivalentOffsetsDiff is the DiffSuppressFunc of offsets, ignoring
+// formatting and the order of the partitions.
+func suppressEquivalentOffsetsDiff(k, old, new string, d *schema.ResourceData) bool {
+	if old == "" || new == "" {
+		return false
+	}
+	a, err := parseOffsets(old)
+	if err != nil {
+		return false
+	}
+	b, err := parseOffsets(new)
+	if err != nil {
+		return false
+	}
+	return offsetsEquivalent(a, b)
+}
+
+// reconcileRemoteOffsets prepares the offsets read from Connect for state.
+// Only the partitions declared locally are compared, so that partitions the
+// user does not manage never show up as drift. If they are equivalent the
+// local representation is kept.
+func reconcileRemoteOffsets(remote []connectorOffset, local string) (string, error) {
+	declared, err := parseOffsets(local)
+	if err != nil {
+		return "", err
+	}
+
+	byPartition := make(map[string]connectorOffset, len(remote))
+	for _, o := range remote {
+		byPartition[offsetKey(o.Partition)] = o
+	}
+
+	current := make([]connectorOffset, 0, len(declared))
+	for _, o := range declared {
+		if r, ok := byPartition[offsetKey(o.Partition)]; ok {
+			current = append(current, r)
+		} else if o.Offset != nil {
+			// a partition without committed offsets has been reset
+			current = append(current, connectorOffset{Partition: o.Partition})
+		}
+	}
+	if offsetsEquivalent(current, nullOffsetsDropped(declared)) {
+		return local, nil
+	}
+
+	b, err := json.Marshal(current)
+	return string(b), err
+}
+
+// nullOffsetsDropped returns the offsets without the partitions that are
+// reset, which Connect no longer reports once they are.
+func nullOffsetsDropped(offsets []connectorOffset) []connectorOffset {
+	kept := make([]connectorOffset, 0, len(offsets))
+	for _, o := range offsets {
+		if o.Offset != nil {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
+
+// applyConnectorOffsets stops the connector, writes the offsets and moves the
+// connector into the given state. An empty list resets all offsets of the
+// connector. Requires Kafka 3.6 or later.
+func applyConnectorOffsets(ctx context.Context, c *client, name string, offsets []connectorOffset, state string, timeout time.Duration) error {
+	if err := applyConnectorState(ctx, c, name, connectorStateStopped, timeout); err != nil {
+		return err
+	}
+
+	err := withRetry(ctx, c.retry, func() error {
+		if len(offsets) == 0 {
+			log.Printf("[INFO] Resetting the offsets of connector %s", name)
+			return c.resetConnectorOffsets(ctx, name)
+		}
+		log.Printf("[INFO] Altering the offsets of %d partitions of connector %s", len(offsets), name)
+		return c.alterConnectorOffsets(ctx, name, offsets)
+	}, timeout)
+	if err != nil {
+		return err
+	}
+
+	if state == connectorStateStopped {
+		return nil
+	}
+	return applyConnectorState(ctx, c, name, state, timeout)
+}
+
+// mergeOffsets returns the base offsets with those of the same partitions
+// replaced by the overrides, followed by overrides of any other partitions.
+func mergeOffsets(base []connectorOffset, overrides []connectorOffset) []connectorOffset {
+	index := map[string]int{}
+	merged := append([]connectorOffset(nil), base...)
+	for i, o := range merged {
+		index[offsetKey(o.Partition)] = i
+	}
+	for _, o := range overrides {
+		if i, ok := index[offsetKey(o.Partition)]; ok {
+			merged[i] = o
+			continue
+		}
+		index[offsetKey(o.Partition)] = len(merged)
+		merged = append(merged, o)
+	}
+	return merged
+}
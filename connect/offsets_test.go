@@ -0,0 +1,261 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testConnect is a fake Connect worker holding the state and offsets of its
+// connectors, recording the requests that change them.
+type testConnect struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	version string
+	// fail is a request, as recorded, answered with a server error instead.
+	fail     string
+	states   map[string]string
+	configs  map[string]map[string]interface{}
+	offsets  map[string]string
+	requests []string
+}
+
+func newTestConnect(t *testing.T) (*testConnect, *httptest.Server) {
+	tc := &testConnect{t: t, version: "3.7.0", states: map[string]string{}, configs: map[string]map[string]interface{}{}, offsets: map[string]string{}}
+	srv := httptest.NewServer(tc)
+	t.Cleanup(srv.Close)
+	return tc, srv
+}
+
+func (tc *testConnect) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/":
+		fmt.Fprintf(w, `{"version":%q,"commit":"abc","kafka_cluster_id":"xyz"}`, tc.version)
+		return
+	case req.Method == http.MethodPut && strings.HasPrefix(req.URL.Path, "/connector-plugins/"):
+		fmt.Fprint(w, `{"name":"jdbc","error_count":0,"configs":[{"definition":{"name":"connection.password","type":"PASSWORD"},"value":{"name":"connection.password","errors":[]}}]}`)
+		return
+	case req.Method == http.MethodPost && req.URL.Path == "/connectors":
+		tc.create(w, req)
+		return
+	case len(parts) < 2 || parts[0] != "connectors":
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	name := parts[1]
+	state, exists := tc.states[name]
+	if !exists && req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error_code":404,"message":"Connector %s not found"}`, name)
+		return
+	}
+
+	action := req.Method + " " + strings.Join(parts[2:], "/")
+	if strings.Join(append(strings.Fields(action), name), " ") == tc.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error_code":500,"message":"injected failure"}`)
+		return
+	}
+	switch action {
+	case "GET ":
+		config := tc.configs[name]
+		if config == nil {
+			config = map[string]interface{}{"name": name}
+		}
+		json.NewEncoder(w).Encode(connectorInfo{Name: name, Config: config, Tasks: []taskID{}})
+		return
+	case "PUT config":
+		config := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+			tc.t.Errorf("invalid config body: %v", err)
+		}
+		tc.configs[name] = config
+		tc.requests = append(tc.requests, "PUT config "+name)
+		json.NewEncoder(w).Encode(connectorInfo{Name: name, Config: config, Tasks: []taskID{}})
+		return
+	case "GET status":
+		fmt.Fprintf(w, `{"name":%q,"connector":{"state":%q,"worker_id":"w1"},"tasks":[{"id":0,"state":%q,"worker_id":"w1"}],"type":"source"}`, name, state, state)
+		return
+	case "GET offsets":
+		fmt.Fprintf(w, `{"offsets":%s}`, tc.offsets[name])
+		return
+	case "PUT stop":
+		tc.states[name] = connectorStateStopped
+	case "PUT pause":
+		tc.states[name] = connectorStatePaused
+	case "PUT resume":
+		tc.states[name] = connectorStateRunning
+	case "PATCH offsets", "DELETE offsets":
+		if state != connectorStateStopped {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error_code":400,"message":"Connectors must be in the STOPPED state before their offsets can be modified"}`)
+			return
+		}
+		body := connectorOffsets{}
+		if req.Method == http.MethodPatch {
+			dec := json.NewDecoder(req.Body)
+			dec.UseNumber()
+			if err := dec.Decode(&body); err != nil {
+				tc.t.Errorf("invalid offsets body: %v", err)
+			}
+		}
+		b, _ := json.Marshal(body.Offsets)
+		tc.offsets[name] = string(b)
+		if body.Offsets == nil {
+			tc.offsets[name] = "[]"
+		}
+	case "DELETE ":
+		delete(tc.states, name)
+		delete(tc.configs, name)
+	default:
+		tc.t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	tc.requests = append(tc.requests, strings.Join(append(strings.Fields(action), name), " "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (tc *testConnect) create(w http.ResponseWriter, req *http.Request) {
+	body := createConnectorRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		tc.t.Errorf("invalid create body: %v", err)
+	}
+	if _, exists := tc.states[body.Name]; exists {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, `{"error_code":409,"message":"Connector %s already exists"}`, body.Name)
+		return
+	}
+
+	tc.states[body.Name] = connectorStateRunning
+	if body.InitialState != "" {
+		tc.states[body.Name] = body.InitialState
+	}
+	tc.configs[body.Name] = body.Config
+	tc.offsets[body.Name] = "[]"
+	tc.requests = append(tc.requests, fmt.Sprintf("POST %s %s", tc.states[body.Name], body.Name))
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(connectorInfo{Name: body.Name, Config: body.Config, Tasks: []taskID{}})
+}
+
+func (tc *testConnect) recorded() []string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return append([]string(nil), tc.requests...)
+}
+
+func TestApplyConnectorOffsets(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStatePaused
+	c := newClient(srv.URL)
+
+	offsets, err := parseOffsets(`[{"partition":{"kafka_topic":"orders","kafka_partition":0},"offset":{"kafka_offset":9007199254740993}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConnectorOffsets(context.Background(), c, "orders", offsets, connectorStatePaused, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[PUT stop orders PATCH offsets orders PUT pause orders]"
+	if got := fmt.Sprint(tc.recorded()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if expected := `[{"partition":{"kafka_partition":0,"kafka_topic":"orders"},"offset":{"kafka_offset":9007199254740993}}]`; tc.offsets["orders"] != expected {
+		t.Errorf("expected offsets %s to be written exactly, got %s", expected, tc.offsets["orders"])
+	}
+
+	if err := applyConnectorOffsets(context.Background(), c, "orders", nil, connectorStateRunning, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	expected = "[PUT stop orders PATCH offsets orders PUT pause orders PUT stop orders DELETE offsets orders PUT resume orders]"
+	if got := fmt.Sprint(tc.recorded()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestReconcileRemoteOffsets(t *testing.T) {
+	local := `[
+		{"partition": {"filename": "b.txt"}, "offset": {"position": 20}},
+		{"partition": {"filename": "a.txt"}, "offset": {"position": 10}},
+		{"partition": {"filename": "c.txt"}, "offset": null}
+	]`
+	cases := map[string]struct {
+		remote   string
+		expected string
+	}{
+		"in sync": {
+			remote:   `[{"partition":{"filename":"a.txt"},"offset":{"position":10}},{"partition":{"filename":"b.txt"},"offset":{"position":20}},{"partition":{"filename":"other.txt"},"offset":{"position":1}}]`,
+			expected: local,
+		},
+		"drifted": {
+			remote:   `[{"partition":{"filename":"a.txt"},"offset":{"position":15}},{"partition":{"filename":"b.txt"},"offset":{"position":20}},{"partition":{"filename":"c.txt"},"offset":{"position":3}}]`,
+			expected: `[{"partition":{"filename":"b.txt"},"offset":{"position":20}},{"partition":{"filename":"a.txt"},"offset":{"position":15}},{"partition":{"filename":"c.txt"},"offset":{"position":3}}]`,
+		},
+		"reset": {
+			remote:   `[{"partition":{"filename":"a.txt"},"offset":{"position":10}}]`,
+			expected: `[{"partition":{"filename":"b.txt"},"offset":null},{"partition":{"filename":"a.txt"},"offset":{"position":10}}]`,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			remote, err := parseOffsets(tt.remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := reconcileRemoteOffsets(remote, local)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConnectorReadOffsets(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStateRunning
+	tc.offsets["orders"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+
+	read := func(offsets string, detectDrift bool) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+			"name":                 "orders",
+			"offsets":              offsets,
+			"detect_offsets_drift": detectDrift,
+		})
+		d.SetId("orders")
+		if diags := connectorRead(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		return d
+	}
+
+	if got := read("", true).Get("offsets").(string); got != "" {
+		t.Errorf("expected unmanaged offsets not to be read, got %s", got)
+	}
+	declared := `[{"partition":{"filename":"a.txt"},"offset":{"position":1}}]`
+	if got := read(declared, false).Get("offsets").(string); got != declared {
+		t.Errorf("expected committed progress not to show as drift, got %s", got)
+	}
+	expected := `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+	if got := read(declared, true).Get("offsets").(string); got != expected {
+		t.Errorf("expected drift to %s, got %s", expected, got)
+	}
+}
@@ -3,13 +3,26 @@ package connect
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"gopkg.in/resty.v1"
-
-	kc "github.com/ricardo-ch/go-kafka-connect/v3/lib/connectors"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/youmark/pkcs8"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
 func Provider() *schema.Provider {
@@ -21,6 +34,14 @@ func Provider() *schema.Provider {
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_URL", ""),
 			},
+			"urls": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"url"},
+				Description:   "URLs of the workers of a single Connect cluster. Requests fail over to the next worker on connection errors and 5xx responses.",
+			},
 			"basic_auth_username": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -46,11 +67,53 @@ func Provider() *schema.Provider {
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_AUTH_KEY", ""),
 			},
+			"tls_root_ca_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_ROOT_CA_PEM", ""),
+				ConflictsWith: []string{"tls_root_ca_file"},
+				Description:   "PEM encoded CA certificates to trust, instead of tls_root_ca_file.",
+			},
+			"tls_client_cert_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_CLIENT_CERT_PEM", ""),
+				ConflictsWith: []string{"tls_auth_crt", "tls_auth_key"},
+				Description:   "PEM encoded client certificate, instead of tls_auth_crt.",
+			},
+			"tls_client_key_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_CLIENT_KEY_PEM", ""),
+				ConflictsWith: []string{"tls_auth_crt", "tls_auth_key"},
+				Description:   "PEM encoded client private key, instead of tls_auth_key.",
+			},
+			"tls_client_key_passphrase": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_CLIENT_KEY_PASSPHRASE", ""),
+				Description: "Passphrase of an encrypted client private key, from tls_client_key_pem or tls_auth_key.",
+			},
 			"tls_auth_is_insecure": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_IS_INSECURE", false),
 			},
+			"tls_min_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_MIN_VERSION", ""),
+				ValidateFunc: validation.StringInSlice([]string{"1.0", "1.1", "1.2", "1.3"}, false),
+				Description:  "Minimum TLS version to accept from Kafka Connect: 1.0, 1.1, 1.2 or 1.3.",
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_TLS_SERVER_NAME", ""),
+				Description: "Server name used for SNI and to verify the certificate of Kafka Connect, when it differs from the host in url.",
+			},
 			"headers": {
 				Type: schema.TypeMap,
 				Elem: &schema.Schema{
@@ -60,10 +123,81 @@ func Provider() *schema.Provider {
 				// No DefaultFunc here to read from the env on account of this issue:
 				// https://github.com/hashicorp/terraform-plugin-sdk/issues/142
 			},
+			"oauth2": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"basic_auth_username", "basic_auth_password"},
+				Description:   "Authenticate with bearer tokens from an OAuth2 client credentials flow.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"token_url": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+							Description:  "Token endpoint of the authorization server.",
+						},
+						"client_id": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"client_secret": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+						"scopes": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"audience": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Audience to request the token for, sent as the audience parameter.",
+						},
+					},
+				},
+			},
+			"credential_helper": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"basic_auth_username", "basic_auth_password", "oauth2"},
+				Description:   "Command and arguments of a helper that prints a JSON object of headers, or a username and password, to authenticate with. It is run again when Kafka Connect answers 401.",
+			},
+			"proxy_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_PROXY_URL", ""),
+				Description: "URL of an http, https or socks5 proxy to reach Kafka Connect through.",
+			},
+			"no_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_NO_PROXY", ""),
+				Description: "Comma separated hosts, domains and CIDRs to reach without the proxy, in the format of NO_PROXY.",
+			},
+			"request_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("KAFKA_CONNECT_REQUEST_TIMEOUT", "10s"),
+				ValidateFunc: validateDuration,
+				Description:  "Timeout of each HTTP request to Kafka Connect, such as 30s. A request that times out is retried if the retry policy allows it.",
+			},
+			"retry": retrySchema(),
+			"preflight_check": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KAFKA_CONNECT_PREFLIGHT_CHECK", false),
+				Description: "Check that Kafka Connect is reachable when configuring the provider, and log its version.",
+			},
 		},
 		ConfigureContextFunc: providerConfigure,
 		ResourcesMap: map[string]*schema.Resource{
-			"kafka-connect_connector": kafkaConnectorResource(),
+			"kafka-connect_connector":         kafkaConnectorResource(),
+			"kafka-connect_connector_offsets": kafkaConnectorOffsetsResource(),
 		},
 	}
 	log.Printf("[INFO] Created provider: %v", provider)
@@ -72,43 +206,366 @@ func Provider() *schema.Provider {
 
 func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	log.Printf("[INFO] Initializing KafkaConnect client")
-	addr := d.Get("url").(string)
-	c := kc.NewClient(addr)
+	var diags diag.Diagnostics
+
+	workers, urlDiags := workerURLsFromRD(d)
+	diags = append(diags, urlDiags...)
+	c := newClient(workers[0])
+	if len(workers) > 1 {
+		c.setWorkers(workers)
+	}
+
+	// validated by the schema, but may come from the environment
+	if timeout, err := time.ParseDuration(d.Get("request_timeout").(string)); err != nil {
+		diags = append(diags, attributeError("request_timeout", "Invalid request timeout", err))
+	} else {
+		c.setRequestTimeout(timeout)
+	}
+
+	retry, err := retryPolicyFromRD(d)
+	if err != nil {
+		diags = append(diags, attributeError("retry", "Invalid retry policy", err))
+	}
+	c.retry = retry
+
+	proxy, err := proxyFromRD(d)
+	if err != nil {
+		diags = append(diags, attributeError("proxy_url", "Invalid proxy URL", err))
+	}
+	c.setProxy(proxy)
+
+	tlsConfig, tlsDiags := tlsConfigFromRD(d)
+	diags = append(diags, tlsDiags...)
+	c.setTLSConfig(tlsConfig)
+
 	user := d.Get("basic_auth_username").(string)
 	pass := d.Get("basic_auth_password").(string)
+	diags = append(diags, requiredTogether(d, "basic_auth_username", "basic_auth_password")...)
 	if user != "" && pass != "" {
-		c.SetBasicAuth(user, pass)
+		c.setBasicAuth(user, pass)
 	}
 
-	tls_root_ca_file := d.Get("tls_root_ca_file").(string)
-	if tls_root_ca_file != "" {
-		resty.SetRootCertificate(tls_root_ca_file)
+	// the token endpoint shares the proxy and TLS settings set above
+	if v, ok := d.GetOk("oauth2"); ok {
+		c.setTokenSource(oauth2TokenSource(v.([]interface{})[0].(map[string]interface{}), c.transport.Clone()))
 	}
 
-	crt := d.Get("tls_auth_crt").(string)
-	key := d.Get("tls_auth_key").(string)
-	is_insecure := d.Get("tls_auth_is_insecure").(bool)
-	log.Printf("[INFO]Cert : %s\nKey: %s", crt, key)
-	log.Printf("[INFO]SSl connection is insecure : %t", is_insecure)
-
-	if crt != "" && key != "" {
-		cert, err := tls.LoadX509KeyPair(crt, key)
-		if err != nil {
-			log.Fatalf("client: loadkeys: %s", err)
-		} else {
-			if is_insecure {
-				c.SetInsecureSSL()
-			}
-			c.SetClientCertificates(cert)
+	if v, ok := d.GetOk("credential_helper"); ok {
+		var command []string
+		for _, arg := range v.([]interface{}) {
+			command = append(command, arg.(string))
 		}
+		c.setCredentialHelper(command)
 	}
 
 	headers := d.Get("headers").(map[string]interface{})
 	if headers != nil {
 		for k, v := range headers {
-			c.SetHeader(k, v.(string))
+			c.setHeader(k, v.(string))
+		}
+	}
+
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// an unknown attribute leaves the client incomplete until the apply
+	if d.Get("preflight_check").(bool) && d.GetRawConfig().IsWhollyKnown() {
+		info, err := c.serverInfo(ctx)
+		if err != nil {
+			return nil, append(diags, attributeError("url", "Unable to reach Kafka Connect", err))
+		}
+		log.Printf("[INFO] Connected to Kafka Connect %s (commit %s) of Kafka cluster %s", info.Version, info.Commit, info.KafkaClusterID)
+	}
+
+	return c, diags
+}
+
+// oauth2TokenSource returns a source of client credentials tokens that are
+// cached and refreshed shortly before they expire.
+// The token endpoint is reached with the given transport, which carries the
+// same proxy and TLS settings as the one used for Connect.
+func oauth2TokenSource(block map[string]interface{}, transport http.RoundTripper) oauth2.TokenSource {
+	config := clientcredentials.Config{
+		ClientID:     block["client_id"].(string),
+		ClientSecret: block["client_secret"].(string),
+		TokenURL:     block["token_url"].(string),
+	}
+	for _, scope := range block["scopes"].([]interface{}) {
+		config.Scopes = append(config.Scopes, scope.(string))
+	}
+	if audience := block["audience"].(string); audience != "" {
+		config.EndpointParams = url.Values{"audience": {audience}}
+	}
+
+	// Tokens are refreshed for as long as the provider runs, well after the
+	// context of the configure call is gone.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	return oauth2.ReuseTokenSource(nil, config.TokenSource(ctx))
+}
+
+// proxySchemes are the proxy URL schemes supported by net/http.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// proxyFromRD returns the proxy function of the transport. Without proxy_url
+// the usual HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply; no_proxy
+// takes precedence over NO_PROXY either way.
+func proxyFromRD(d *schema.ResourceData) (func(*http.Request) (*url.URL, error), error) {
+	config := httpproxy.FromEnvironment()
+
+	if proxyURL := d.Get("proxy_url").(string); proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return http.ProxyFromEnvironment, err
+		}
+		if !slices.Contains(proxySchemes, u.Scheme) || u.Host == "" {
+			return http.ProxyFromEnvironment, fmt.Errorf("%q must be a %s URL with a host", proxyURL, strings.Join(proxySchemes, ", "))
+		}
+		config.HTTPProxy = proxyURL
+		config.HTTPSProxy = proxyURL
+	}
+	if noProxy := d.Get("no_proxy").(string); noProxy != "" {
+		config.NoProxy = noProxy
+	}
+
+	proxy := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// tlsMinVersions maps the values accepted by tls_min_version to their
+// crypto/tls constants.
+var tlsMinVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfigFromRD builds the TLS settings of a single provider instance from
+// its tls_* attributes.
+func tlsConfigFromRD(d *schema.ResourceData) (*tls.Config, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	config := &tls.Config{
+		InsecureSkipVerify: d.Get("tls_auth_is_insecure").(bool),
+		ServerName:         d.Get("tls_server_name").(string),
+	}
+	log.Printf("[INFO]SSl connection is insecure : %t", config.InsecureSkipVerify)
+
+	if v := d.Get("tls_min_version").(string); v != "" {
+		config.MinVersion = tlsMinVersions[v]
+	}
+
+	caKey, caPEM, err := pemFromRD(d, "tls_root_ca_pem", "tls_root_ca_file")
+	if err != nil {
+		diags = append(diags, attributeError(caKey, "Invalid TLS root CA", err))
+	} else if caPEM != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			diags = append(diags, attributeError(caKey, "Invalid TLS root CA",
+				fmt.Errorf("%s does not contain any PEM encoded certificate", caKey)))
 		}
+		config.RootCAs = pool
+	}
+
+	log.Printf("[INFO]Cert : %s\nKey: %s", d.Get("tls_auth_crt"), d.Get("tls_auth_key"))
+	diags = append(diags, requiredTogether(d, "tls_auth_crt", "tls_auth_key")...)
+	diags = append(diags, requiredTogether(d, "tls_client_cert_pem", "tls_client_key_pem")...)
+	if diags.HasError() {
+		return config, diags
 	}
 
-	return c, nil
+	certKey, certPEM, err := pemFromRD(d, "tls_client_cert_pem", "tls_auth_crt")
+	if err != nil {
+		return config, append(diags, attributeError(certKey, "Invalid TLS client certificate", err))
+	}
+	keyKey, keyPEM, err := pemFromRD(d, "tls_client_key_pem", "tls_auth_key")
+	if err != nil {
+		return config, append(diags, attributeError(keyKey, "Invalid TLS client key", err))
+	}
+	if certPEM != nil && keyPEM != nil {
+		cert, err := clientCertificate(certPEM, keyPEM, d.Get("tls_client_key_passphrase").(string))
+		if err != nil {
+			diags = append(diags, attributeError(certKey, "Invalid TLS client certificate", err))
+		} else {
+			config.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return config, diags
+}
+
+// workerURLsFromRD returns the worker URLs from urls, or else the single
+// url, validating each of them.
+func workerURLsFromRD(d *schema.ResourceData) ([]string, diag.Diagnostics) {
+	// only checked once known, on the configure of the apply
+	if !isKnown(d, "url") || !isKnown(d, "urls") {
+		return []string{d.Get("url").(string)}, nil
+	}
+
+	v, ok := d.GetOk("urls")
+	if !ok {
+		addr := d.Get("url").(string)
+		if err := validateConnectURL(addr); err != nil {
+			return []string{addr}, diag.Diagnostics{attributeError("url", "Invalid Kafka Connect URL", err)}
+		}
+		return []string{addr}, nil
+	}
+
+	var workers []string
+	var diags diag.Diagnostics
+	for i, w := range v.([]interface{}) {
+		addr, _ := w.(string)
+		if err := validateConnectURL(addr); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid Kafka Connect URL",
+				Detail:        err.Error(),
+				AttributePath: cty.GetAttrPath("urls").IndexInt(i),
+			})
+		}
+		workers = append(workers, addr)
+	}
+	return workers, diags
+}
+
+// validateConnectURL checks that addr is an absolute http(s) URL.
+func validateConnectURL(addr string) error {
+	if addr == "" {
+		return errors.New("url must be set, either in the provider block or with KAFKA_CONNECT_URL")
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use the http or https scheme", addr)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", addr)
+	}
+	return nil
+}
+
+// pemFromRD returns the PEM content of the inline attribute, or else of the
+// file named by the path attribute, along with the attribute it came from.
+// Only one of the two may be set; both may come from the environment, so
+// ConflictsWith alone cannot enforce that.
+func pemFromRD(d *schema.ResourceData, inlineKey string, pathKey string) (string, []byte, error) {
+	inline := d.Get(inlineKey).(string)
+	path := d.Get(pathKey).(string)
+	switch {
+	case inline != "" && path != "":
+		return inlineKey, nil, fmt.Errorf("only one of %s and %s can be set", inlineKey, pathKey)
+	case inline != "":
+		return inlineKey, []byte(inline), nil
+	case path != "":
+		content, err := os.ReadFile(path)
+		return pathKey, content, err
+	default:
+		return pathKey, nil, nil
+	}
+}
+
+// clientCertificate pairs the certificate with its private key, decrypting
+// the key with the passphrase if it is encrypted. Both PKCS#8 encrypted keys
+// and legacy OpenSSL encrypted PEM blocks are supported.
+func clientCertificate(certPEM []byte, keyPEM []byte, passphrase string) (tls.Certificate, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, errors.New("the private key is not PEM encoded")
+	}
+
+	encrypted := block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block)
+	if encrypted && passphrase == "" {
+		return tls.Certificate{}, errors.New("the private key is encrypted but tls_client_key_passphrase is not set")
+	}
+	if !encrypted {
+		return tls.X509KeyPair(certPEM, keyPEM)
+	}
+
+	var key interface{}
+	var err error
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		key, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+	} else {
+		// the legacy format is deprecated as insecure, but keys written by
+		// older tooling still use it
+		var der []byte
+		der, err = x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err == nil {
+			key, err = parsePrivateKey(der)
+		}
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("decrypt private key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// parsePrivateKey parses a DER encoded PKCS#1, PKCS#8 or SEC 1 private key.
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+// requiredTogether returns an error diagnostic for each of the attributes
+// that is empty while the others are set. Nothing is checked while any of
+// them is unknown.
+func requiredTogether(d *schema.ResourceData, keys ...string) diag.Diagnostics {
+	var set, unset []string
+	for _, k := range keys {
+		if !isKnown(d, k) {
+			return nil
+		}
+		if d.Get(k).(string) != "" {
+			set = append(set, k)
+		} else {
+			unset = append(unset, k)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	var diags diag.Diagnostics
+	for _, k := range unset {
+		diags = append(diags, attributeError(k, "Missing required attribute",
+			fmt.Errorf("%s must be set together with %s", k, strings.Join(set, ", "))))
+	}
+	return diags
+}
+
+// isKnown reports whether the value of the attribute is known. Values that
+// come from resources created in the same apply are unknown while planning,
+// and read as empty.
+func isKnown(d *schema.ResourceData, key string) bool {
+	raw := d.GetRawConfig()
+	if raw.IsNull() {
+		return true
+	}
+	return raw.IsKnown() && raw.GetAttr(key).IsWhollyKnown()
+}
+
+func attributeError(key string, summary string, err error) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       summary,
+		Detail:        err.Error(),
+		AttributePath: cty.GetAttrPath(key),
+	}
 }
@@ -1,10 +1,30 @@
 package connect
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/youmark/pkcs8"
 )
 
 var testProvider *schema.Provider
@@ -30,3 +50,495 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatalf("%s env var must be set", connectVar)
 	}
 }
+
+func TestProviderConfigureDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		raw    map[string]interface{}
+		errors []string
+	}{
+		{
+			name:   "valid",
+			raw:    map[string]interface{}{"url": "http://localhost:8083"},
+			errors: nil,
+		},
+		{
+			name:   "url without scheme",
+			raw:    map[string]interface{}{"url": "localhost:8083"},
+			errors: []string{"url"},
+		},
+		{
+			name:   "url without host",
+			raw:    map[string]interface{}{"url": "http://"},
+			errors: []string{"url"},
+		},
+		{
+			name:   "invalid worker url",
+			raw:    map[string]interface{}{"urls": []interface{}{"http://localhost:8083", "localhost:8084"}},
+			errors: []string{"urls"},
+		},
+		{
+			name:   "unreadable ca file",
+			raw:    map[string]interface{}{"url": "http://localhost:8083", "tls_root_ca_file": filepath.Join(dir, "missing.pem")},
+			errors: []string{"tls_root_ca_file"},
+		},
+		{
+			name:   "ca file without certificates",
+			raw:    map[string]interface{}{"url": "http://localhost:8083", "tls_root_ca_file": notPEM},
+			errors: []string{"tls_root_ca_file"},
+		},
+		{
+			name:   "certificate without key",
+			raw:    map[string]interface{}{"url": "http://localhost:8083", "tls_auth_crt": notPEM},
+			errors: []string{"tls_auth_key"},
+		},
+		{
+			name:   "invalid key pair",
+			raw:    map[string]interface{}{"url": "http://localhost:8083", "tls_auth_crt": notPEM, "tls_auth_key": notPEM},
+			errors: []string{"tls_auth_crt"},
+		},
+		{
+			name:   "username without password",
+			raw:    map[string]interface{}{"url": "http://localhost:8083", "basic_auth_username": "user"},
+			errors: []string{"basic_auth_password"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, Provider().Schema, tc.raw)
+			_, diags := providerConfigure(context.Background(), d)
+
+			var got []string
+			for _, diag := range diags {
+				got = append(got, diag.AttributePath[0].(cty.GetAttrStep).Name)
+			}
+			if !reflect.DeepEqual(got, tc.errors) {
+				t.Errorf("expected errors on %v, got %v: %v", tc.errors, got, diags)
+			}
+		})
+	}
+}
+
+func TestProviderConfigureUnknown(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	cases := map[string]map[string]cty.Value{
+		"url":                 {"url": cty.UnknownVal(cty.String)},
+		"urls":                {"urls": cty.UnknownVal(cty.List(cty.String))},
+		"worker url":          {"urls": cty.ListVal([]cty.Value{cty.StringVal(srv.URL), cty.UnknownVal(cty.String)})},
+		"basic auth password": {"url": cty.StringVal(srv.URL), "basic_auth_username": cty.StringVal("user"), "basic_auth_password": cty.UnknownVal(cty.String)},
+		"client key":          {"url": cty.StringVal(srv.URL), "tls_client_cert_pem": cty.StringVal("cert"), "tls_client_key_pem": cty.UnknownVal(cty.String)},
+	}
+	for name, attrs := range cases {
+		t.Run(name, func(t *testing.T) {
+			attrs["preflight_check"] = cty.True
+			resp := testConfigureProvider(t, attrs)
+			if len(resp.Diagnostics) > 0 {
+				t.Errorf("expected unknown values to be accepted, got %v", resp.Diagnostics[0])
+			}
+			if n := atomic.LoadInt32(&requests); n != 0 {
+				t.Errorf("expected no preflight check, got %d requests", n)
+			}
+		})
+	}
+}
+
+// testConfigureProvider configures the provider as Terraform does, with the
+// given attributes and all others null.
+func testConfigureProvider(t *testing.T, attrs map[string]cty.Value) *tfprotov5.ConfigureProviderResponse {
+	t.Helper()
+
+	p := Provider()
+	ty := schema.InternalMap(p.Schema).CoreConfigSchema().ImpliedType()
+	vals := make(map[string]cty.Value)
+	for k, at := range ty.AttributeTypes() {
+		vals[k] = cty.NullVal(at)
+	}
+	for k, v := range attrs {
+		vals[k] = v
+	}
+	b, err := msgpack.Marshal(cty.ObjectVal(vals), ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := schema.NewGRPCProviderServer(p).ConfigureProvider(context.Background(), &tfprotov5.ConfigureProviderRequest{
+		Config: &tfprotov5.DynamicValue{MsgPack: b},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestProviderConfigurePreflight(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":             srv.URL,
+		"preflight_check": true,
+	})
+	if _, diags := providerConfigure(context.Background(), d); diags.HasError() {
+		t.Fatalf("expected the preflight check to pass, got %v", diags)
+	}
+
+	srv.Close()
+	_, diags := providerConfigure(context.Background(), d)
+	if !diags.HasError() {
+		t.Fatal("expected the preflight check to fail once the worker is gone")
+	}
+	if got := diags[0].AttributePath[0].(cty.GetAttrStep).Name; got != "url" {
+		t.Errorf("expected the preflight error on url, got %s", got)
+	}
+}
+
+func TestProviderConfigurePerInstanceTLS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	})
+
+	dir := t.TempDir()
+	start := func(name string) (*httptest.Server, *client) {
+		cert, certPEM, _ := testCertificate(t)
+		srv := httptest.NewUnstartedServer(handler)
+		srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+
+		ca := filepath.Join(dir, name+".pem")
+		if err := os.WriteFile(ca, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"url":              srv.URL,
+			"tls_root_ca_file": ca,
+			"tls_min_version":  "1.2",
+			"tls_server_name":  "connect.example.com",
+		})
+		meta, diags := providerConfigure(context.Background(), d)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		return srv, meta.(*client)
+	}
+
+	first, firstClient := start("first")
+	_, secondClient := start("second")
+
+	if _, err := firstClient.serverInfo(context.Background()); err != nil {
+		t.Errorf("expected the first provider to trust its own CA, got %v", err)
+	}
+	if _, err := secondClient.serverInfo(context.Background()); err != nil {
+		t.Errorf("expected the second provider to trust its own CA, got %v", err)
+	}
+
+	secondClient.rest.SetHostURL(first.URL)
+	if _, err := secondClient.serverInfo(context.Background()); err == nil {
+		t.Error("expected the second provider not to trust the CA of the first")
+	}
+}
+
+// testCertificate returns a self-signed certificate for connect.example.com
+// along with its PEM encoded certificate and key.
+func testCertificate(t *testing.T) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "connect.example.com"},
+		DNSNames:              []string{"connect.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certPEM, keyPEM
+}
+
+func TestProviderConfigureInlinePEM(t *testing.T) {
+	serverCert, caPEM, _ := testCertificate(t)
+	clientCert, clientCertPEM, clientKeyPEM := testCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":                 srv.URL,
+		"tls_server_name":     "connect.example.com",
+		"tls_root_ca_pem":     string(caPEM),
+		"tls_client_cert_pem": string(clientCertPEM),
+		"tls_client_key_pem":  string(clientKeyPEM),
+		"preflight_check":     true,
+	})
+	if _, diags := providerConfigure(context.Background(), d); diags.HasError() {
+		t.Fatalf("expected the inline certificates to be used, got %v", diags)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":              srv.URL,
+		"tls_root_ca_pem":  string(caPEM),
+		"tls_root_ca_file": "ca.pem",
+	})
+	_, diags := providerConfigure(context.Background(), d)
+	if !diags.HasError() || diags[0].AttributePath[0].(cty.GetAttrStep).Name != "tls_root_ca_pem" {
+		t.Errorf("expected an error for setting both tls_root_ca_pem and tls_root_ca_file, got %v", diags)
+	}
+}
+
+func TestClientCertificateEncryptedKey(t *testing.T) {
+	_, certPEM, keyPEM := testCertificate(t)
+	block, _ := pem.Decode(keyPEM)
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8DER, err := pkcs8.MarshalPrivateKey(key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBlock, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", block.Bytes, []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string][]byte{
+		"pkcs8":  pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8DER}),
+		"legacy": pem.EncodeToMemory(legacyBlock),
+	}
+	for name, encrypted := range keys {
+		t.Run(name, func(t *testing.T) {
+			if _, err := clientCertificate(certPEM, encrypted, "secret"); err != nil {
+				t.Errorf("expected the key to be decrypted, got %v", err)
+			}
+			if _, err := clientCertificate(certPEM, encrypted, "wrong"); err == nil {
+				t.Error("expected an error for a wrong passphrase")
+			}
+			if _, err := clientCertificate(certPEM, encrypted, ""); err == nil {
+				t.Error("expected an error for a missing passphrase")
+			}
+		})
+	}
+}
+
+func TestProviderConfigureOAuth2(t *testing.T) {
+	var issued int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Form.Get("audience"); got != "connect" {
+			t.Errorf("expected audience connect, got %q", got)
+		}
+		if got := r.Form.Get("scope"); got != "connect:read connect:write" {
+			t.Errorf("expected both scopes, got %q", got)
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		// tokens this short lived are refreshed before every request
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":1}`, n)
+	}))
+	defer idp.Close()
+
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url": srv.URL,
+		"oauth2": []interface{}{map[string]interface{}{
+			"token_url":     idp.URL,
+			"client_id":     "terraform",
+			"client_secret": "secret",
+			"scopes":        []interface{}{"connect:read", "connect:write"},
+			"audience":      "connect",
+		}},
+	})
+	meta, diags := providerConfigure(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	c := meta.(*client)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.serverInfo(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"Bearer token-1", "Bearer token-2"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected requests with %v, got %v", expected, seen)
+	}
+}
+
+func TestProviderConfigureOAuth2TLS(t *testing.T) {
+	idp := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer idp.Close()
+
+	var seen string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	}))
+	defer srv.Close()
+
+	// the identity provider is trusted through the provider's CA only
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.Certificate().Raw})
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":             srv.URL,
+		"tls_root_ca_pem": string(ca),
+		"oauth2": []interface{}{map[string]interface{}{
+			"token_url":     idp.URL,
+			"client_id":     "terraform",
+			"client_secret": "secret",
+		}},
+	})
+	meta, diags := providerConfigure(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if _, err := meta.(*client).serverInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if seen != "Bearer token" {
+		t.Errorf("expected the token from the identity provider, got %q", seen)
+	}
+}
+
+func TestProviderConfigureProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		fmt.Fprint(w, `{"version":"3.7.0","commit":"abc","kafka_cluster_id":"cluster"}`)
+	}))
+	defer proxy.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":       "http://connect.example.com:8083",
+		"proxy_url": proxy.URL,
+		"no_proxy":  "internal.example.com,10.0.0.0/8",
+	})
+	meta, diags := providerConfigure(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	c := meta.(*client)
+
+	if _, err := c.serverInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"http://connect.example.com:8083/"}; !reflect.DeepEqual(proxied, expected) {
+		t.Errorf("expected %v to be proxied, got %v", expected, proxied)
+	}
+
+	for target, expected := range map[string]string{
+		"https://connect.example.com":          proxy.URL,
+		"https://connect.internal.example.com": "",
+		"http://10.1.2.3:8083":                 "",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		u, err := c.transport.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(u); (expected == "" && u != nil) || (expected != "" && got != expected) {
+			t.Errorf("expected %s to use proxy %q, got %v", target, expected, u)
+		}
+	}
+}
+
+func TestProviderConfigureProxyURL(t *testing.T) {
+	for proxyURL, valid := range map[string]bool{
+		"socks5://bastion:1080": true,
+		"https://proxy:3128":    true,
+		"ftp://proxy:21":        false,
+		"socks5://":             false,
+	} {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"url":       "http://connect.example.com:8083",
+			"proxy_url": proxyURL,
+		})
+		_, diags := providerConfigure(context.Background(), d)
+		if diags.HasError() == valid {
+			t.Errorf("expected %s to be valid: %t, got %v", proxyURL, valid, diags)
+		}
+	}
+}
+
+func TestProviderConfigureRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"url":             srv.URL,
+		"request_timeout": "50ms",
+	})
+	meta, diags := providerConfigure(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	_, err := meta.(*client).serverInfo(context.Background())
+	if retryCondition(err) != retryOnTimeout {
+		t.Errorf("expected the request to time out, got %v", err)
+	}
+}
@@ -1,25 +1,41 @@
 package connect
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	kc "github.com/ricardo-ch/go-kafka-connect/v3/lib/connectors"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func kafkaConnectorResource() *schema.Resource {
 	return &schema.Resource{
-		Create: connectorCreate,
-		Read:   connectorRead,
-		Update: connectorUpdate,
-		Delete: connectorDelete,
+		CreateContext: connectorCreate,
+		ReadContext:   connectorRead,
+		UpdateContext: connectorUpdate,
+		DeleteContext: connectorDelete,
 		Importer: &schema.ResourceImporter{
-			State: setNameFromID,
+			StateContext: setNameFromID,
+		},
+		CustomizeDiff: connectorCustomizeDiff,
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    kafkaConnectorResourceV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: kafkaConnectorStateUpgradeV0,
+			},
 		},
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(60 * time.Second),
@@ -28,29 +44,181 @@ func kafkaConnectorResource() *schema.Resource {
 		},
 		Schema: map[string]*schema.Schema{
 			"name": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
+				Type:     schema.TypeString,
+				Required: true,
+				// replaced unless preserve_offsets_on_rename is set; see
+				// connectorCustomizeDiff
 				Description: "The name of the connector",
 			},
 			"config": {
-				Type:        schema.TypeMap,
-				Optional:    true,
-				ForceNew:    false,
-				Description: "A map of string k/v attributes.",
+				Type:     schema.TypeMap,
+				Optional: true,
+				// Computed so that keys the plugin defines as passwords can be
+				// moved to config_sensitive while planning.
+				Computed:         true,
+				ForceNew:         false,
+				DiffSuppressFunc: suppressEquivalentConfigDiff,
+				Description:      "A map of string k/v attributes. Keys the connector plugin defines as passwords are moved to config_sensitive.",
 			},
 			"config_sensitive": {
 				Type:        schema.TypeMap,
 				Optional:    true,
+				Computed:    true,
 				ForceNew:    false,
 				Sensitive:   true,
 				Description: "A map of string k/v attributes which are sensitive, such as passwords.",
 			},
+			"config_sensitive_wo": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				WriteOnly:    true,
+				Sensitive:    true,
+				ValidateFunc: validation.StringIsJSON,
+				RequiredWith: []string{"config_sensitive_wo_version"},
+				Description:  "A JSON object of sensitive string k/v attributes which is sent to Connect but never stored in state. Requires Terraform 1.11 or later.",
+			},
+			"config_sensitive_wo_version": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "A version or hash of config_sensitive_wo. Changing it pushes the current write-only values to Connect.",
+			},
+			"config_sensitive_wo_keys": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The keys set by config_sensitive_wo, which are left out of config.",
+			},
+			"state": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					connectorStateRunning,
+					connectorStatePaused,
+					connectorStateStopped,
+				}, false),
+				Description: "The desired state of the connector: RUNNING, PAUSED or STOPPED. STOPPED requires Kafka 3.5 or later.",
+			},
+			"initial_state": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					connectorStateRunning,
+					connectorStatePaused,
+					connectorStateStopped,
+				}, false),
+				// only used on create, so changing it later does nothing
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return d.Id() != ""
+				},
+				Description: "The state to create the connector in: RUNNING, PAUSED or STOPPED. Only used on create. Requires Kafka 3.7 or later.",
+			},
+			"wait_for_running": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Wait for the connector and all of its tasks to report RUNNING after a create or update, failing if any of them fail.",
+			},
+			"preserve_offsets_on_rename": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Rename the connector in place, moving its offsets to the new name, instead of replacing it. Requires Kafka 3.7 or later.",
+			},
+			"delete_offsets_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Stop the connector and reset its offsets before deleting it, so a connector re-created with the same name starts from scratch. Requires Kafka 3.6 or later.",
+			},
+			"offsets": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateFunc:     validateOffsets,
+				DiffSuppressFunc: suppressEquivalentOffsetsDiff,
+				Description:      "JSON list of partition and offset objects to set on the connector, as accepted by PATCH /connectors/{name}/offsets. An empty list resets all offsets. Requires Kafka 3.6 or later, or 3.7 when set on create.",
+			},
+			"detect_offsets_drift": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Read back the offsets of the partitions declared in offsets, so that offsets committed by the running connector show up as drift and the next apply rewinds it to the declared position.",
+			},
+			"connector_state": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The state of the connector as reported by Connect, e.g. RUNNING, PAUSED, FAILED or UNASSIGNED.",
+			},
+			"worker_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The worker the connector is assigned to.",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The connector type, source or sink.",
+			},
+			"tasks": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The tasks of the connector and their state.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"state": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"worker_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"trace": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
 		},
 	}
 }
 
-func setNameFromID(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+var errConfigNameMismatch = errors.New("config.name must be identical to the resource name")
+
+// kafkaConnectorResourceV0 is the schema of states written before config.name
+// was injected by the provider.
+func kafkaConnectorResourceV0() *schema.Resource {
+	return &schema.Resource{
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Second),
+			Update: schema.DefaultTimeout(60 * time.Second),
+			Delete: schema.DefaultTimeout(60 * time.Second),
+		},
+		Schema: map[string]*schema.Schema{
+			"name":             {Type: schema.TypeString, Required: true, ForceNew: true},
+			"config":           {Type: schema.TypeMap, Optional: true},
+			"config_sensitive": {Type: schema.TypeMap, Optional: true, Sensitive: true},
+		},
+	}
+}
+
+// kafkaConnectorStateUpgradeV0 removes config.name from states in which it
+// duplicates the resource name, as the provider now leaves it out of config.
+func kafkaConnectorStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if config, ok := rawState["config"].(map[string]interface{}); ok {
+		if name, ok := rawState["name"].(string); ok {
+			removeConnectorName(config, name)
+		}
+	}
+	return rawState, nil
+}
+
+func setNameFromID(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
 
 	connectorName := d.Id()
 	log.Printf("Import connector with name: %s", connectorName)
@@ -59,65 +227,132 @@ func setNameFromID(d *schema.ResourceData, meta interface{}) ([]*schema.Resource
 	return []*schema.ResourceData{d}, nil
 }
 
-func connectorCreate(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(kc.HighLevelClient)
+func connectorCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*client)
 	name := nameFromRD(d)
 
-	config, sensitiveCache := configFromRD(d)
-	if n, ok := config["name"]; ok && n != name {
-		return errors.New("config.name must be identical to the resource name")
-	} else if !ok {
-		return errors.New("config.name is the mandatory field identical to the resource name")
+	// RUNNING is what older workers create connectors in anyway
+	initialState, attribute := d.Get("initial_state").(string), "initial_state"
+	if initialState == connectorStateRunning {
+		initialState = ""
+	}
+	// seeded offsets must be in place before the connector first runs
+	if _, ok := d.GetOk("offsets"); ok {
+		initialState, attribute = connectorStateStopped, "offsets"
+	}
+	if initialState != "" {
+		if err := requireConnectVersion(ctx, c, attribute+" on create", 3, 7); err != nil {
+			return diag.Diagnostics{{
+				Severity:      diag.Error,
+				Summary:       "Unsupported " + attribute,
+				Detail:        err.Error(),
+				AttributePath: cty.GetAttrPath(attribute),
+			}}
+		}
 	}
 
-	req := kc.CreateConnectorRequest{
-		ConnectorRequest: kc.ConnectorRequest{
-			Name: name,
-		},
-		Config: config,
+	if err := createConnectorFromRD(ctx, c, d, initialState, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diagFromErr(err)
+	}
+	d.SetId(name)
+	createdState := initialState
+	if createdState == "" {
+		createdState = connectorStateRunning
 	}
 
-	// Use retry logic for CreateConnector to handle race conditions
-	// where connector is created but GetConnector returns 409 if called too quickly
-	var connectorResponse kc.ConnectorResponse
-	err := withRebalanceRetry(func() error {
-		var createErr error
-		connectorResponse, createErr = c.CreateConnector(req, true)
-		return createErr
-	}, d.Timeout(schema.TimeoutCreate))
+	if v, ok := d.GetOk("offsets"); ok {
+		offsets, err := parseOffsets(v.(string))
+		if err != nil {
+			return diagFromErr(err)
+		}
+		err = applyConnectorOffsets(ctx, c, name, offsets, desiredState(d), d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return diagFromErr(err)
+		}
+	} else if state := desiredState(d); state != createdState {
+		err := applyConnectorState(ctx, c, name, state, d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return diagFromErr(err)
+		}
+	}
 
-	fmt.Printf("[INFO] Created the connector %v\n", connectorResponse)
+	if shouldWaitForRunning(d) {
+		config, sensitiveCache := configFromRD(d)
+		if diags := waitForConnectorRunning(ctx, c, name, config, keysOf(sensitiveCache), d.Timeout(schema.TimeoutCreate)); diags.HasError() {
+			return diags
+		}
+	}
 
-	if err == nil {
-		newConfFiltered := removeSecondKeysFromFirst(connectorResponse.Config, sensitiveCache)
-		d.SetId(name)
-		d.Set("config_sensitive", sensitiveCache)
-		d.Set("config", newConfFiltered)
+	return diagFromErr(readWithRetry(ctx, d, meta, d.Timeout(schema.TimeoutCreate)))
+}
+
+// createConnectorFromRD creates the connector from the merged config maps and
+// records the config Connect returns. Setting the ID is left to the caller.
+func createConnectorFromRD(ctx context.Context, c *client, d *schema.ResourceData, initialState string, timeout time.Duration) error {
+	name := nameFromRD(d)
+
+	config, sensitiveCache := configFromRD(d)
+	writeOnly, err := writeOnlyConfigFromRD(d)
+	if err != nil {
+		return err
+	}
+	config = combineMaps(config, writeOnly)
+	if err := injectConnectorName(config, name); err != nil {
+		return err
+	}
+	if err := addPasswordKeys(ctx, c, config, sensitiveCache, writeOnly); err != nil {
+		return err
 	}
 
+	// Use retry logic for createConnector to handle race conditions
+	// where connector is created but getConnector returns 409 if called too quickly
+	var connectorResponse connectorInfo
+	err = withRetry(ctx, c.retry, func() error {
+		var createErr error
+		connectorResponse, createErr = c.createConnector(ctx, name, config, initialState, timeout)
+		return createErr
+	}, timeout)
 	if err != nil {
 		return err
 	}
 
-	return readWithRetry(d, meta, d.Timeout(schema.TimeoutCreate))
+	// the response holds the sensitive and write-only values
+	log.Printf("[INFO] Created the connector %s", name)
+
+	newConfFiltered := removeConnectorName(removeSecondKeysFromFirst(connectorResponse.Config, combineMaps(sensitiveCache, writeOnly)), name)
+	d.Set("config_sensitive", sensitiveCache)
+	d.Set("config_sensitive_wo_keys", keysOf(writeOnly))
+	d.Set("config", newConfFiltered)
+
+	return nil
 }
 
-func connectorDelete(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(kc.HighLevelClient)
+func connectorDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*client)
 
 	name := nameFromRD(d)
-	req := kc.ConnectorRequest{
-		Name: name,
+
+	if d.Get("delete_offsets_on_destroy").(bool) {
+		err := applyConnectorOffsets(ctx, c, name, nil, connectorStateStopped, d.Timeout(schema.TimeoutDelete))
+		if isNotFoundError(err) {
+			log.Printf("[INFO] Connector %s was already deleted, not resetting its offsets", name)
+		} else if err != nil {
+			return diagFromErr(err)
+		}
 	}
 
 	fmt.Printf("[INFO] Deleting the connector %s\n", name)
 
-	err := withRebalanceRetry(func() error {
-		_, derr := c.DeleteConnector(req, true)
+	err := withRetry(ctx, c.retry, func() error {
+		derr := c.deleteConnector(ctx, name, d.Timeout(schema.TimeoutDelete))
+		if isNotFoundError(derr) {
+			log.Printf("[INFO] Connector %s was already deleted", name)
+			return nil
+		}
 		return derr
 	}, d.Timeout(schema.TimeoutDelete))
 	if err != nil {
-		return err
+		return diagFromErr(err)
 	}
 
 	d.SetId("")
@@ -125,149 +360,773 @@ func connectorDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func connectorUpdate(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(kc.HighLevelClient)
+func connectorUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*client)
 
 	name := nameFromRD(d)
 
-	config, sensitiveCache := configFromRD(d)
-	if n, ok := config["name"]; ok && n != name {
-		return errors.New("config.name must be identical to the resource name")
-	} else if !ok {
-		return errors.New("config.name is the mandatory field identical to the resource name")
+	// a rename is only planned in place with preserve_offsets_on_rename, and
+	// creates the connector with its current config, offsets and state
+	if d.HasChange("name") {
+		if err := renameConnector(ctx, c, d); err != nil {
+			return diagFromErr(err)
+		}
+	} else if diags := updateConnectorInPlace(ctx, c, d); diags.HasError() {
+		return diags
 	}
 
-	log.Printf("[INFO] Requesting update to connector %v", name)
-	req := kc.CreateConnectorRequest{
-		ConnectorRequest: kc.ConnectorRequest{
-			Name: name,
-		},
-		Config: config,
+	if shouldWaitForRunning(d) {
+		config, sensitiveCache := configFromRD(d)
+		if diags := waitForConnectorRunning(ctx, c, name, config, keysOf(sensitiveCache), d.Timeout(schema.TimeoutUpdate)); diags.HasError() {
+			return diags
+		}
+	}
+
+	return diagFromErr(readWithRetry(ctx, d, meta, d.Timeout(schema.TimeoutUpdate)))
+}
+
+// updateConnectorInPlace applies changes to the config, offsets and state of
+// a connector that keeps its name.
+func updateConnectorInPlace(ctx context.Context, c *client, d *schema.ResourceData) diag.Diagnostics {
+	name := nameFromRD(d)
+
+	// removing offsets only stops managing them
+	var offsets []connectorOffset
+	alterOffsets := d.HasChange("offsets") && d.Get("offsets").(string) != ""
+	if alterOffsets {
+		var err error
+		if offsets, err = parseOffsets(d.Get("offsets").(string)); err != nil {
+			return diagFromErr(err)
+		}
+		// stop before the new config is applied, so that it never runs from
+		// the old position
+		err = applyConnectorState(ctx, c, name, connectorStateStopped, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return diagFromErr(err)
+		}
 	}
 
-	log.Printf("[INFO] Looking for %s", name)
-	var conn kc.ConnectorResponse
-	var err error
-	err = withRebalanceRetry(func() error {
-		conn, err = c.UpdateConnector(req, true)
+	if d.HasChanges("config", "config_sensitive", "config_sensitive_wo_version") {
+		if err := updateConnectorConfig(ctx, c, d); err != nil {
+			return diagFromErr(err)
+		}
+	}
+
+	if alterOffsets {
+		err := applyConnectorOffsets(ctx, c, name, offsets, desiredState(d), d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return diagFromErr(err)
+		}
+	} else if d.HasChange("state") {
+		if state, ok := d.GetOk("state"); ok {
+			err := applyConnectorState(ctx, c, name, state.(string), d.Timeout(schema.TimeoutUpdate))
+			if err != nil {
+				return diagFromErr(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// renameConnector moves the connector to its new name without losing its
+// position. The old connector is stopped and its offsets read, the new one is
+// created stopped and given those offsets, overridden by any declared in
+// offsets, and moved to its desired state. Until then state keeps tracking the
+// old connector, and a failure deletes the new connector and returns the old
+// one to its prior state, so the next apply retries the rename. The old
+// connector is only deleted once the new one took over.
+func renameConnector(ctx context.Context, c *client, d *schema.ResourceData) error {
+	o, _ := d.GetChange("name")
+	oldName, name := o.(string), nameFromRD(d)
+	timeout := d.Timeout(schema.TimeoutUpdate)
+
+	if err := requireConnectVersion(ctx, c, "preserve_offsets_on_rename", 3, 7); err != nil {
+		return err
+	}
+
+	// on error the prior state, which names the old connector, is kept
+	d.Partial(true)
+
+	log.Printf("[INFO] Renaming connector %s to %s", oldName, name)
+	if err := moveConnectorToName(ctx, c, d, oldName, timeout); err != nil {
+		return rollBackRename(ctx, c, d, oldName, err)
+	}
+	d.SetId(name)
+	d.Partial(false)
+
+	log.Printf("[INFO] Deleting connector %s, renamed to %s", oldName, name)
+	err := withRetry(ctx, c.retry, func() error {
+		derr := c.deleteConnector(ctx, oldName, timeout)
+		if isNotFoundError(derr) {
+			return nil
+		}
+		return derr
+	}, timeout)
+	if err != nil {
+		return fmt.Errorf("connector %s was renamed to %s, but the stopped old connector must be deleted by hand: %w", oldName, name, err)
+	}
+	return nil
+}
+
+// moveConnectorToName stops the old connector and creates the new one with
+// its offsets, in the desired state.
+func moveConnectorToName(ctx context.Context, c *client, d *schema.ResourceData, oldName string, timeout time.Duration) error {
+	name := nameFromRD(d)
+
+	if err := applyConnectorState(ctx, c, oldName, connectorStateStopped, timeout); err != nil {
+		return err
+	}
+	var offsets []connectorOffset
+	err := withRetry(ctx, c.retry, func() error {
+		var err error
+		offsets, err = c.getConnectorOffsets(ctx, oldName)
+		return err
+	}, timeout)
+	if err != nil {
+		return err
+	}
+
+	if v := d.Get("offsets").(string); v != "" {
+		declared, err := parseOffsets(v)
+		if err != nil {
+			return err
+		}
+		offsets = mergeOffsets(offsets, declared)
+	}
+
+	if err := createConnectorFromRD(ctx, c, d, connectorStateStopped, timeout); err != nil {
+		return err
+	}
+	if len(offsets) > 0 {
+		return applyConnectorOffsets(ctx, c, name, offsets, desiredState(d), timeout)
+	}
+	if state := desiredState(d); state != connectorStateStopped {
+		return applyConnectorState(ctx, c, name, state, timeout)
+	}
+	return nil
+}
+
+// rollBackRename deletes the new connector, if it was created, and returns
+// the old one to the state it was in before the rename.
+func rollBackRename(ctx context.Context, c *client, d *schema.ResourceData, oldName string, cause error) error {
+	name := nameFromRD(d)
+	timeout := d.Timeout(schema.TimeoutUpdate)
+	log.Printf("[WARN] Renaming connector %s to %s failed, rolling back: %v", oldName, name, cause)
+
+	err := withRetry(ctx, c.retry, func() error {
+		derr := c.deleteConnector(ctx, name, timeout)
+		if isNotFoundError(derr) {
+			return nil
+		}
+		return derr
+	}, timeout)
+	if err != nil {
+		return fmt.Errorf("%w; rolling back, deleting connector %s failed: %w", cause, name, err)
+	}
+
+	prior, _ := d.GetChange("state")
+	if state := prior.(string); state != "" && state != connectorStateStopped {
+		if err := applyConnectorState(ctx, c, oldName, state, timeout); err != nil {
+			return fmt.Errorf("%w; rolling back, restoring connector %s to %s failed: %w", cause, oldName, state, err)
+		}
+	}
+	return cause
+}
+
+// updateConnectorConfig pushes the merged config and config_sensitive maps to
+// Connect.
+func updateConnectorConfig(ctx context.Context, c *client, d *schema.ResourceData) error {
+	name := nameFromRD(d)
+
+	config, sensitiveCache := configFromRD(d)
+	// write-only values are only in the raw config and must be resent with
+	// every update, as Connect replaces the whole config
+	writeOnly, err := writeOnlyConfigFromRD(d)
+	if err != nil {
+		return err
+	}
+	config = combineMaps(config, writeOnly)
+	if err := injectConnectorName(config, name); err != nil {
+		return err
+	}
+	if err := addPasswordKeys(ctx, c, config, sensitiveCache, writeOnly); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Requesting update to connector %v", name)
+	var conn connectorInfo
+	err = withRetry(ctx, c.retry, func() error {
+		conn, err = c.updateConnector(ctx, name, config, keysOf(combineMaps(sensitiveCache, writeOnly)), d.Timeout(schema.TimeoutUpdate))
 		return err
 	}, d.Timeout(schema.TimeoutUpdate))
 
 	if err == nil {
-		newConfFiltered := removeSecondKeysFromFirst(conn.Config, sensitiveCache)
+		newConfFiltered := removeConnectorName(removeSecondKeysFromFirst(conn.Config, combineMaps(sensitiveCache, writeOnly)), name)
 		//log.Printf("[INFO] Full config received from update is: %v", conn.Config)
 		log.Printf("[INFO] Local config nonsensitive updated to: %v", newConfFiltered)
 		//log.Printf("[INFO] Local config_sensitive updated to:  %v", sensitiveCache)
 		d.Set("config", newConfFiltered)
 		d.Set("config_sensitive", sensitiveCache)
+		d.Set("config_sensitive_wo_keys", keysOf(writeOnly))
 	}
 
-	if err != nil {
-		return err
+	return err
+}
+
+func connectorRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	err := readConnector(ctx, d, meta)
+
+	if isNotFoundError(err) {
+		name := nameFromRD(d)
+		log.Printf("[WARN] Connector %s not found, removing from state", name)
+		d.SetId("")
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Connector not found",
+			Detail:   fmt.Sprintf("Connector %q no longer exists in Kafka Connect and has been removed from state. It will be re-created on the next apply.", name),
+		}}
 	}
 
-	return readWithRetry(d, meta, d.Timeout(schema.TimeoutUpdate))
+	return diagFromErr(err)
 }
 
-func connectorRead(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(kc.HighLevelClient)
+// readConnector refreshes the resource data from Connect, returning an error
+// for which isNotFoundError holds if the connector no longer exists.
+func readConnector(ctx context.Context, d *schema.ResourceData, meta interface{}) error {
+	c := meta.(*client)
 
-	config, sensitiveCache := configFromRD(d)
+	_, sensitiveCache := configFromRD(d)
 	name := d.Get("name").(string)
-	req := kc.ConnectorRequest{
-		Name: name,
-	}
 
 	log.Printf("[INFO] Attempting to read remote data for connector %s", name)
-	log.Printf("[INFO] Current local config nonsensitive values are: %v", config)
+	log.Printf("[INFO] Current local config nonsensitive values are: %v", mapFromRD(d, "config"))
 	//log.Printf("[INFO] Current local config_sensitive values are: %v", sensitiveCache)
-	conn, err := c.GetConnector(req)
+	conn, err := c.getConnector(ctx, name)
 
 	if err != nil {
 		return err
 	}
 
+	refreshedSensitive := refreshSensitiveConfig(conn.Config, sensitiveCache)
+
 	// we do not want the sensitive values to appear in the non-masked 'config' field
 	// use cached sensitive values to get the correct keys to remove from the newly read config
-	newConfFiltered := removeSecondKeysFromFirst(conn.Config, sensitiveCache)
-	d.Set("config_sensitive", sensitiveCache)
+	newConfFiltered := removeConnectorName(removeSecondKeysFromFirst(conn.Config, sensitiveCache), name)
+	newConfFiltered = reconcileRemoteConfig(newConfFiltered, mapFromRD(d, "config"))
+	for _, k := range d.Get("config_sensitive_wo_keys").(*schema.Set).List() {
+		delete(newConfFiltered, k.(string))
+	}
+	d.Set("config_sensitive", refreshedSensitive)
 	d.Set("config", newConfFiltered)
 	log.Printf("[INFO] Local config nonsensitive data updated to %v", newConfFiltered)
+
+	// the status of a freshly created connector can lag behind its config
+	status, err := c.getConnectorStatus(ctx, name)
+	if err != nil && !isNotFoundError(err) {
+		return err
+	}
+	if err == nil {
+		setConnectorStatus(d, status)
+	}
+
+	// offsets the running connector commits are progress, and are only
+	// refreshed when asked for
+	if local := d.Get("offsets").(string); local != "" && d.Get("detect_offsets_drift").(bool) {
+		remote, err := c.getConnectorOffsets(ctx, name)
+		if err != nil {
+			return err
+		}
+		offsets, err := reconcileRemoteOffsets(remote, local)
+		if err != nil {
+			return err
+		}
+		d.Set("offsets", offsets)
+	}
 	//log.Printf("[INFO] Local config_sensitive data updated to %v", sensitiveCache)
 
 	return nil
 }
 
-// readWithRetry wraps the connectorRead function with retry functionality that
-// will attempt to read the connector again if a rebalance operation is
-// detected.
-func readWithRetry(d *schema.ResourceData, meta interface{}, timeout time.Duration) error {
-	return withRebalanceRetry(func() error {
-		return connectorRead(d, meta)
+// setConnectorStatus copies the live connector and task status into the
+// resource data.
+func setConnectorStatus(d *schema.ResourceData, status connectorStatus) {
+	tasks := make([]map[string]interface{}, 0, len(status.Tasks))
+	for _, task := range status.Tasks {
+		tasks = append(tasks, map[string]interface{}{
+			"id":        task.ID,
+			"state":     task.State,
+			"worker_id": task.WorkerID,
+			"trace":     task.Trace,
+		})
+	}
+
+	d.Set("state", desiredStateFromStatus(status.Connector.State))
+	d.Set("connector_state", status.Connector.State)
+	d.Set("worker_id", status.Connector.WorkerID)
+	d.Set("type", status.Type)
+	d.Set("tasks", tasks)
+}
+
+// connectorCustomizeDiff validates the planned config against the plugin and
+// moves keys the plugin defines as passwords from config into
+// config_sensitive, so that they are masked in plans and kept out of the
+// plain config in state.
+func connectorCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	// Connect keys offsets by connector name, so a rename replaces the
+	// connector and restarts it from scratch unless opted out of
+	if d.Id() != "" && d.HasChange("name") && !d.Get("preserve_offsets_on_rename").(bool) {
+		if err := d.ForceNew("name"); err != nil {
+			return err
+		}
+	}
+
+	if !d.NewValueKnown("config") || !d.NewValueKnown("config_sensitive") {
+		return nil
+	}
+
+	config := rawConfigMap(d, "config")
+	sensitive := rawConfigMap(d, "config_sensitive")
+
+	// the name is injected on apply, so a name matching the resource name is
+	// left out of the planned config just as Read leaves it out of state
+	if d.NewValueKnown("name") {
+		if n, ok := config["name"]; ok && n != nameFromRD(d) {
+			return cty.GetAttrPath("config").Index(cty.StringVal("name")).NewError(errConfigNameMismatch)
+		}
+		removeConnectorName(config, nameFromRD(d))
+	}
+
+	// keys detected as passwords by an earlier plan stay masked
+	oldConfig, _ := d.GetChange("config")
+	oldSensitive, _ := d.GetChange("config_sensitive")
+	moveSensitiveKeys(config, sensitive, keysOf(oldSensitive.(map[string]interface{})))
+
+	writeOnly, err := writeOnlyConfigFromRD(d)
+	if err != nil {
+		return err
+	}
+	if d.Id() == "" || d.HasChange("config_sensitive_wo_version") {
+		if err := d.SetNew("config_sensitive_wo_keys", keysOf(writeOnly)); err != nil {
+			return err
+		}
+	}
+
+	// the planned config is rebuilt from the raw config here, so values that
+	// only differ in formatting from state must be suppressed again
+	config = preferLocalConfigValues(config, oldConfig.(map[string]interface{}))
+
+	changed := !reflect.DeepEqual(config, oldConfig) || !reflect.DeepEqual(sensitive, oldSensitive) ||
+		d.HasChange("config_sensitive_wo_version")
+	if c, ok := meta.(*client); ok && c != nil && (d.Id() == "" || changed) {
+		result, err := validateConfig(ctx, c, nameFromRD(d), config, combineMaps(sensitive, writeOnly))
+		if err != nil {
+			return err
+		}
+		moveSensitiveKeys(config, sensitive, passwordConfigKeys(result))
+	}
+
+	if !reflect.DeepEqual(config, d.Get("config")) {
+		if err := d.SetNew("config", config); err != nil {
+			return err
+		}
+	}
+	if !reflect.DeepEqual(sensitive, d.Get("config_sensitive")) {
+		if err := d.SetNew("config_sensitive", sensitive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateConfig sends the merged config to the plugin's validate endpoint so
+// that invalid values are reported by terraform plan rather than halfway
+// through an apply.
+func validateConfig(ctx context.Context, c *client, name string, config map[string]interface{}, sensitive map[string]interface{}) (configValidation, error) {
+	merged := combineMaps(config, sensitive)
+	if err := injectConnectorName(merged, name); err != nil {
+		return configValidation{}, err
+	}
+	class, _ := merged["connector.class"].(string)
+	if class == "" {
+		return configValidation{}, nil
+	}
+
+	log.Printf("[INFO] Validating config of connector %s against %s", name, class)
+	result, err := c.validateConnectorConfig(ctx, class, merged)
+	if err != nil {
+		return configValidation{}, err
+	}
+
+	return result, configValidationErrors(result, merged, sensitive)
+}
+
+// passwordConfigKeys returns the keys the plugin defines with type PASSWORD.
+func passwordConfigKeys(result configValidation) []string {
+	var keys []string
+	for _, info := range result.Configs {
+		if info.Definition.Type == "PASSWORD" {
+			keys = append(keys, info.Definition.Name)
+		}
+	}
+	return keys
+}
+
+// addPasswordKeys adds the keys of config the plugin defines as passwords to
+// sensitive, so that they are kept out of config in state. The plan already
+// does so, except when config holds values that were unknown until apply.
+// Write-only keys are never stored and are left alone.
+func addPasswordKeys(ctx context.Context, c *client, config map[string]interface{}, sensitive map[string]interface{}, writeOnly map[string]interface{}) error {
+	class, _ := config["connector.class"].(string)
+	if class == "" {
+		return nil
+	}
+	result, err := c.validateConnectorConfig(ctx, class, config)
+	if err != nil {
+		return err
+	}
+	for _, k := range passwordConfigKeys(result) {
+		_, isSensitive := sensitive[k]
+		_, isWriteOnly := writeOnly[k]
+		if !isSensitive && !isWriteOnly {
+			if v, ok := config[k]; ok {
+				log.Printf("[INFO] Treating config key %s as sensitive, as the plugin defines it as a password", k)
+				sensitive[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// moveSensitiveKeys moves the given keys from config into sensitive. A value
+// already present in sensitive wins, as it does in configFromRD.
+func moveSensitiveKeys(config map[string]interface{}, sensitive map[string]interface{}, keys []string) {
+	for _, k := range keys {
+		v, ok := config[k]
+		if !ok {
+			continue
+		}
+		if _, ok := sensitive[k]; !ok {
+			sensitive[k] = v
+		}
+		delete(config, k)
+	}
+}
+
+// rawConfigMap returns the map the user wrote for key. Unlike d.Get it does
+// not fall back to the prior state when the attribute is omitted.
+func rawConfigMap(d *schema.ResourceDiff, key string) map[string]interface{} {
+	m := make(map[string]interface{})
+	raw := d.GetRawConfig()
+	if raw.IsNull() || !raw.IsKnown() {
+		for k, v := range mapFromRD(d, key) {
+			m[k] = v
+		}
+		return m
+	}
+	v := raw.GetAttr(key)
+	if v.IsNull() || !v.IsWhollyKnown() {
+		return m
+	}
+	for it := v.ElementIterator(); it.Next(); {
+		k, e := it.Element()
+		if !e.IsNull() {
+			m[k.AsString()] = e.AsString()
+		}
+	}
+	return m
+}
+
+// writeOnlyConfigFromRD decodes config_sensitive_wo. Write-only values are
+// never persisted, so they are only available from the raw config of the
+// current plan or apply.
+func writeOnlyConfigFromRD(d interface{ GetRawConfig() cty.Value }) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	raw := d.GetRawConfig()
+	if raw.IsNull() || !raw.IsKnown() {
+		return config, nil
+	}
+	v := raw.GetAttr("config_sensitive_wo")
+	if v.IsNull() || !v.IsKnown() {
+		return config, nil
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(v.AsString()), &values); err != nil {
+		return nil, errors.New("config_sensitive_wo must be a JSON object of string values")
+	}
+	for k, v := range values {
+		config[k] = v
+	}
+	return config, nil
+}
+
+func keysOf(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// configValidationErrors turns the per-key errors of a validation response
+// into errors scoped to the offending attribute. Values of sensitive keys are
+// redacted from the messages Connect returns.
+func configValidationErrors(result configValidation, config map[string]interface{}, sensitive map[string]interface{}) error {
+	var errs []error
+	for _, info := range result.Configs {
+		key := info.Value.Name
+		path := cty.GetAttrPath("config")
+		if _, ok := sensitive[key]; ok {
+			path = cty.GetAttrPath("config_sensitive").Index(cty.StringVal(key))
+		} else if _, ok := config[key]; ok {
+			path = path.Index(cty.StringVal(key))
+		}
+
+		for _, msg := range info.Value.Errors {
+			if v, ok := sensitive[key].(string); ok && v != "" {
+				msg = strings.ReplaceAll(msg, v, "(sensitive value)")
+			}
+			errs = append(errs, path.NewErrorf("invalid value for config key %q: %s", key, msg))
+		}
+	}
+
+	// Terraform only scopes a single error returned from a diff to its
+	// attribute, so several errors are reported together.
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// maskedSensitiveValue is what Connect returns for PASSWORD config values on
+// workers configured to hide them.
+const maskedSensitiveValue = "[hidden]"
+
+// configProviderPlaceholder matches ConfigProvider references such as
+// ${file:/opt/secrets.properties:password}, which Connect returns unresolved.
+var configProviderPlaceholder = regexp.MustCompile(`\$\{[^}:]+:[^}]*\}`)
+
+// refreshSensitiveConfig compares each cached sensitive value with the value
+// Connect returned. The values are compared by hash, and a value that changed
+// on the cluster is replaced by the hash of the remote one: the plan then
+// shows a (masked) diff for the key without the remote secret being written
+// to state. Values Connect masks or that reference a ConfigProvider cannot be
+// compared and are kept as they are.
+func refreshSensitiveConfig(remote map[string]interface{}, cached map[string]interface{}) map[string]interface{} {
+	refreshed := make(map[string]interface{}, len(cached))
+	for k, v := range cached {
+		r, ok := remote[k]
+		if !ok {
+			log.Printf("[INFO] Sensitive config key %s was removed from the connector", k)
+			continue
+		}
+
+		remoteValue := fmt.Sprint(r)
+		if remoteValue == maskedSensitiveValue || configProviderPlaceholder.MatchString(remoteValue) {
+			refreshed[k] = v
+			continue
+		}
+
+		remoteHash := sensitiveValueHash(remoteValue)
+		if remoteHash != sensitiveValueHash(fmt.Sprint(v)) {
+			log.Printf("[INFO] Sensitive config key %s changed on the connector", k)
+			refreshed[k] = remoteHash
+			continue
+		}
+		refreshed[k] = v
+	}
+	return refreshed
+}
+
+func sensitiveValueHash(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// readWithRetry wraps the readConnector function with retry functionality that
+// will attempt to read the connector again if a rebalance operation, or any
+// other condition the retry policy allows, is detected.
+func readWithRetry(ctx context.Context, d *schema.ResourceData, meta interface{}, timeout time.Duration) error {
+	return withRetry(ctx, meta.(*client).retry, func() error {
+		return readConnector(ctx, d, meta)
 	}, timeout)
 }
 
-// withRebalanceRetry executes the provided function with exponential backoff
-// retry logic specifically designed to handle Kafka Connect rebalancing
-// scenarios. The timeout parameter specifies how long to wait for rebalancing
-// to complete.
-func withRebalanceRetry(fn func() error, timeout time.Duration) error {
+// applyConnectorState moves the connector into the desired state and waits
+// for Connect to report that it got there.
+func applyConnectorState(ctx context.Context, c *client, name string, state string, timeout time.Duration) error {
+	log.Printf("[INFO] Moving connector %s to state %s", name, state)
+	err := withRetry(ctx, c.retry, func() error {
+		return c.changeConnectorState(ctx, name, state)
+	}, timeout)
+	if err != nil {
+		return err
+	}
+
+	return waitForConnectorState(ctx, c, name, state, timeout)
+}
+
+// waitForConnectorState polls the connector status until it reports the
+// desired state or the timeout expires.
+func waitForConnectorState(ctx context.Context, c *client, name string, state string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
-	backoff := 250 * time.Millisecond
-	const maxBackoff = 5 * time.Second
 	for {
-		err := fn()
-		if err == nil {
+		status, err := c.getConnectorStatus(ctx, name)
+		if err != nil && !isNotFoundError(err) && !c.retry.retryable(err) {
+			return err
+		}
+		if err == nil &&
+			desiredStateFromStatus(status.Connector.State) == state {
 			return nil
 		}
-		if !isRebalanceError(err) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for connector %s to reach state %s", name, state)
+		}
+		if err := sleepContext(ctx, time.Second); err != nil {
 			return err
 		}
-		if time.Now().After(deadline) {
-			return fmt.Errorf("timed out waiting for Kafka Connect rebalance to finish: %w", err)
-		}
-		jitter := time.Duration(rand.Int63n(int64(backoff / 2)))
-		sleep := backoff + jitter
-		log.Printf("[INFO] Connect rebalance in progress; retrying after %.2fs ... (%v)", sleep.Seconds(), err)
-		time.Sleep(sleep)
-		if backoff < maxBackoff {
-			backoff *= 2
-			if backoff > maxBackoff {
-				backoff = maxBackoff
+	}
+}
+
+// desiredState returns the state the connector should be in: the configured
+// one, else the one last read, else the one it is created in, else RUNNING.
+func desiredState(d resourceGetter) string {
+	if state := d.Get("state").(string); state != "" {
+		return state
+	}
+	if state := d.Get("initial_state").(string); state != "" {
+		return state
+	}
+	return connectorStateRunning
+}
+
+// shouldWaitForRunning reports whether the user asked to wait for the
+// connector to run, which only makes sense when it is meant to be running.
+func shouldWaitForRunning(d *schema.ResourceData) bool {
+	return d.Get("wait_for_running").(bool) && desiredState(d) == connectorStateRunning
+}
+
+// waitForConnectorRunning polls the connector until Connect serves the given
+// config and the connector and each task Connect lists for it are RUNNING.
+// Task states are only trusted once the config is served, so that the tasks
+// of the previous config are not taken for those of the new one. A FAILED
+// connector or task stops the wait and its stack trace is returned in the
+// diagnostic. UNASSIGNED connectors and tasks, as seen while the group
+// rebalances, are waited out.
+func waitForConnectorRunning(ctx context.Context, c *client, name string, config map[string]interface{}, sensitiveKeys []string, timeout time.Duration) diag.Diagnostics {
+	log.Printf("[INFO] Waiting for connector %s and its tasks to be RUNNING", name)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := c.getConnector(ctx, name)
+		var status connectorStatus
+		if err == nil && configApplied(conn.Config, config, sensitiveKeys) {
+			status, err = c.getConnectorStatus(ctx, name)
+			if err == nil {
+				if diags := connectorStatusDiags(status); diags.HasError() {
+					return diags
+				}
+				if connectorIsRunning(status, conn.Tasks) {
+					return nil
+				}
 			}
 		}
+		if err != nil && !isNotFoundError(err) && !c.retry.retryable(err) {
+			return diagFromErr(err)
+		}
+		if time.Now().After(deadline) {
+			return diag.Errorf("timed out waiting for connector %s and its tasks to be RUNNING", name)
+		}
+		if err := sleepContext(ctx, time.Second); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 }
 
-// isRebalanceError tries to detect the Connect 409 window and related exceptions.
-func isRebalanceError(err error) bool {
-	msg := strings.ToLower(err.Error())
-	if strings.Contains(msg, "rebalance") ||
-		strings.Contains(msg, "rebalanceexpected") ||
-		strings.Contains(msg, "rebalance is expected") ||
-		strings.Contains(msg, "conflicting operation") {
-		return true
+// connectorStatusDiags returns an error diagnostic for the connector and each
+// of its tasks that Connect reports as FAILED.
+func connectorStatusDiags(status connectorStatus) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if status.Connector.State == "FAILED" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Connector %s failed on worker %s", status.Name, status.Connector.WorkerID),
+			Detail:   status.Connector.Trace,
+		})
+	}
+	for _, task := range status.Tasks {
+		if task.State == "FAILED" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Task %d of connector %s failed on worker %s", task.ID, status.Name, task.WorkerID),
+				Detail:   task.Trace,
+			})
+		}
 	}
+	return diags
+}
 
-	return strings.Contains(msg, "409")
+// connectorIsRunning reports whether the connector and each of its tasks, as
+// listed by Connect, are RUNNING. A connector Connect lists no tasks for is
+// running on its own.
+func connectorIsRunning(status connectorStatus, tasks []taskID) bool {
+	if status.Connector.State != connectorStateRunning {
+		return false
+	}
+	states := make(map[int]string, len(status.Tasks))
+	for _, task := range status.Tasks {
+		states[task.ID] = task.State
+	}
+	for _, task := range tasks {
+		if states[task.Task] != connectorStateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// injectConnectorName sets config.name to the resource name, which Connect
+// requires. A config.name set by the user is only accepted if it matches.
+func injectConnectorName(config map[string]interface{}, name string) error {
+	if n, ok := config["name"]; ok && n != name {
+		return errConfigNameMismatch
+	}
+	config["name"] = name
+	return nil
+}
+
+// removeConnectorName drops the name Connect echoes back in the config, so it
+// does not show up as a diff for users who never wrote it.
+func removeConnectorName(config map[string]interface{}, name string) map[string]interface{} {
+	if n, ok := config["name"]; ok && n == name {
+		delete(config, "name")
+	}
+	return config
 }
 
 // Returns a full config (inclusive of sensitive values) and a config of just the sensitive values
 // The first is intended to be passed to CreateConnectorRequest
 // The second is intended to preserve knowledge of which keys are sensitive information in the incoming
 // ConnectorResponse.Config
-func configFromRD(d *schema.ResourceData) (map[string]interface{}, map[string]interface{}) {
+func configFromRD(d resourceGetter) (map[string]interface{}, map[string]interface{}) {
 	cfg := mapFromRD(d, "config")
 	scfg := mapFromRD(d, "config_sensitive")
 	config := combineMaps(cfg, scfg)
 	return config, scfg
 }
 
-func nameFromRD(d *schema.ResourceData) string {
+func nameFromRD(d resourceGetter) string {
 	return d.Get("name").(string)
 }
 
-func mapFromRD(d *schema.ResourceData, key string) map[string]interface{} {
+// resourceGetter is implemented by both *schema.ResourceData and
+// *schema.ResourceDiff, so the config helpers work at plan and apply time.
+type resourceGetter interface {
+	Get(key string) interface{}
+}
+
+func mapFromRD(d resourceGetter, key string) map[string]interface{} {
 	return d.Get(key).(map[string]interface{})
 }
 
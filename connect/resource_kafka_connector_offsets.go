@@ -0,0 +1,147 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func kafkaConnectorOffsetsResource() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: connectorOffsetsWrite,
+		ReadContext:   connectorOffsetsRead,
+		UpdateContext: connectorOffsetsWrite,
+		DeleteContext: connectorOffsetsDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: connectorOffsetsImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Second),
+			Update: schema.DefaultTimeout(60 * time.Second),
+		},
+		Schema: map[string]*schema.Schema{
+			"connector_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The name of the connector whose offsets are managed",
+			},
+			"offsets": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateFunc:     validateOffsets,
+				DiffSuppressFunc: suppressEquivalentOffsetsDiff,
+				Description:      "JSON list of partition and offset objects, as accepted by PATCH /connectors/{name}/offsets. An empty list resets all offsets. Requires Kafka 3.6 or later.",
+			},
+			"detect_drift": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Read back the offsets of the declared partitions, so that offsets committed by the running connector show up as drift and the next apply rewinds it to the declared position.",
+			},
+		},
+	}
+}
+
+func connectorOffsetsImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("connector_name", d.Id())
+	return []*schema.ResourceData{d}, nil
+}
+
+// connectorOffsetsWrite stops the connector, writes the offsets and restores
+// the state the connector was in.
+func connectorOffsetsWrite(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*client)
+	name := d.Get("connector_name").(string)
+	timeout := d.Timeout(schema.TimeoutUpdate)
+	if d.IsNewResource() {
+		timeout = d.Timeout(schema.TimeoutCreate)
+	}
+
+	// only the offsets are written; detect_drift only affects reads
+	if !d.IsNewResource() && !d.HasChange("offsets") {
+		return connectorOffsetsRead(ctx, d, meta)
+	}
+
+	offsets, err := parseOffsets(d.Get("offsets").(string))
+	if err != nil {
+		return diagFromErr(err)
+	}
+
+	var status connectorStatus
+	err = withRetry(ctx, c.retry, func() error {
+		status, err = c.getConnectorStatus(ctx, name)
+		return err
+	}, timeout)
+	if isNotFoundError(err) {
+		return diag.Errorf("connector %s does not exist", name)
+	}
+	if err != nil {
+		return diagFromErr(err)
+	}
+
+	prior := desiredStateFromStatus(status.Connector.State)
+	log.Printf("[INFO] Writing the offsets of connector %s, which is %s", name, prior)
+	if err := applyConnectorOffsets(ctx, c, name, offsets, prior, timeout); err != nil {
+		return diagFromErr(err)
+	}
+
+	d.SetId(name)
+	return connectorOffsetsRead(ctx, d, meta)
+}
+
+func connectorOffsetsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*client)
+	name := d.Get("connector_name").(string)
+
+	_, err := c.getConnector(ctx, name)
+	if isNotFoundError(err) {
+		log.Printf("[WARN] Connector %s not found, removing its offsets from state", name)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diagFromErr(err)
+	}
+
+	// offsets the running connector commits are progress, not drift, unless
+	// asked for
+	local := d.Get("offsets").(string)
+	if local != "" && !d.Get("detect_drift").(bool) {
+		return nil
+	}
+
+	remote, err := c.getConnectorOffsets(ctx, name)
+	if err != nil {
+		return diagFromErr(err)
+	}
+
+	// without local offsets, as on import, all offsets are adopted
+	if local == "" {
+		b, err := json.Marshal(remote)
+		if err != nil {
+			return diagFromErr(err)
+		}
+		d.Set("offsets", string(b))
+		return nil
+	}
+
+	offsets, err := reconcileRemoteOffsets(remote, local)
+	if err != nil {
+		return diagFromErr(fmt.Errorf("offsets of connector %s: %w", name, err))
+	}
+	d.Set("offsets", offsets)
+	return nil
+}
+
+// connectorOffsetsDelete only removes the offsets from state; the connector
+// keeps its current position.
+func connectorOffsetsDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,106 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestConnectorOffsetsWriteRestoresState(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStatePaused
+	tc.offsets["orders"] = "[]"
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorOffsetsResource().Schema, map[string]interface{}{
+		"connector_name": "orders",
+		"offsets":        `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`,
+	})
+	d.MarkNewResource()
+	if diags := connectorOffsetsWrite(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if d.Id() != "orders" {
+		t.Errorf("expected the ID to be the connector name, got %q", d.Id())
+	}
+	expected := "[PUT stop orders PATCH offsets orders PUT pause orders]"
+	if got := fmt.Sprint(tc.recorded()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if tc.states["orders"] != connectorStatePaused {
+		t.Errorf("expected the connector to be paused again, got %s", tc.states["orders"])
+	}
+}
+
+func TestConnectorOffsetsWriteMissingConnector(t *testing.T) {
+	tc, srv := newTestConnect(t)
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorOffsetsResource().Schema, map[string]interface{}{
+		"connector_name": "orders",
+		"offsets":        `[]`,
+	})
+	diags := connectorOffsetsWrite(context.Background(), d, newClient(srv.URL))
+	if !diags.HasError() || diags[0].Summary != "connector orders does not exist" {
+		t.Errorf("expected a missing connector error, got %v", diags)
+	}
+	if got := tc.recorded(); len(got) != 0 {
+		t.Errorf("expected no changes, got %v", got)
+	}
+}
+
+func TestConnectorOffsetsRead(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStateRunning
+	tc.offsets["orders"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+
+	read := func(name, offsets string, detectDrift bool) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, kafkaConnectorOffsetsResource().Schema, map[string]interface{}{
+			"connector_name": name,
+			"offsets":        offsets,
+			"detect_drift":   detectDrift,
+		})
+		d.SetId(name)
+		if diags := connectorOffsetsRead(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		return d
+	}
+
+	expected := tc.offsets["orders"]
+	if got := read("orders", "", false).Get("offsets").(string); got != expected {
+		t.Errorf("expected imported offsets %s, got %s", expected, got)
+	}
+	declared := `[{"partition":{"filename":"a.txt"},"offset":{"position":1}}]`
+	if got := read("orders", declared, false).Get("offsets").(string); got != declared {
+		t.Errorf("expected committed progress not to show as drift, got %s", got)
+	}
+	if got := read("orders", declared, true).Get("offsets").(string); got != expected {
+		t.Errorf("expected drift to %s, got %s", expected, got)
+	}
+	if d := read("missing", "[]", false); d.Id() != "" {
+		t.Errorf("expected offsets of a missing connector to be removed from state, got ID %q", d.Id())
+	}
+}
+
+func TestConnectorOffsetsUpdateDetectDrift(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStateRunning
+	tc.offsets["orders"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+
+	prior := schema.TestResourceDataRaw(t, kafkaConnectorOffsetsResource().Schema, map[string]interface{}{
+		"connector_name": "orders",
+		"offsets":        `[{"partition":{"filename":"a.txt"},"offset":{"position":1}}]`,
+	})
+	prior.SetId("orders")
+	d := kafkaConnectorOffsetsResource().Data(prior.State())
+	d.Set("detect_drift", true)
+
+	if diags := connectorOffsetsWrite(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got := tc.recorded(); len(got) != 0 {
+		t.Errorf("expected only detect_drift changing not to write offsets, got %v", got)
+	}
+}
@@ -1,14 +1,22 @@
 package connect
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	r "github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
-	kc "github.com/ricardo-ch/go-kafka-connect/v3/lib/connectors"
 )
 
 func TestAccConnectorConfigUpdate(t *testing.T) {
@@ -53,6 +61,23 @@ func TestAccConnectorWithTimeouts(t *testing.T) {
 	})
 }
 
+func TestAccConnectorState(t *testing.T) {
+	r.Test(t, r.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			{
+				Config: fmt.Sprintf(testResourceConnector_stateConfig, "PAUSED"),
+				Check:  r.TestCheckResourceAttr("kafka-connect_connector.test_state", "state", "PAUSED"),
+			},
+			{
+				Config: fmt.Sprintf(testResourceConnector_stateConfig, "RUNNING"),
+				Check:  r.TestCheckResourceAttr("kafka-connect_connector.test_state", "state", "RUNNING"),
+			},
+		},
+	})
+}
+
 func testResourceConnector_initialCheck(s *terraform.State) error {
 	resourceState := s.Modules[0].Resources["kafka-connect_connector.test"]
 	if resourceState == nil {
@@ -70,9 +95,7 @@ func testResourceConnector_initialCheck(s *terraform.State) error {
 		return fmt.Errorf("id doesn't match name")
 	}
 
-	client := testProvider.Meta().(kc.HighLevelClient)
-
-	c, err := client.GetConnector(kc.ConnectorRequest{Name: "sqlite-sink"})
+	c, err := testProvider.Meta().(*client).getConnector(context.Background(), "sqlite-sink")
 	if err != nil {
 		return err
 	}
@@ -87,9 +110,7 @@ func testResourceConnector_initialCheck(s *terraform.State) error {
 }
 
 func testResourceConnector_updateCheck(s *terraform.State) error {
-	client := testProvider.Meta().(kc.HighLevelClient)
-
-	c, err := client.GetConnector(kc.ConnectorRequest{Name: "sqlite-sink"})
+	c, err := testProvider.Meta().(*client).getConnector(context.Background(), "sqlite-sink")
 	if err != nil {
 		return err
 	}
@@ -155,8 +176,28 @@ resource "kafka-connect_connector" "test_timeouts" {
 }
 `
 
+const testResourceConnector_stateConfig = `
+resource "kafka-connect_connector" "test_state" {
+  name  = "test-state"
+  state = "%s"
+
+  config = {
+    "connector.class" = "io.confluent.connect.jdbc.JdbcSinkConnector"
+    "tasks.max"       = "1"
+    "topics"          = "test-topic"
+    "connection.url"  = "jdbc:sqlite:test.db"
+    "auto.create"     = "true"
+  }
+}
+`
+
+// testRebalanceError is the error Connect answers with while it rebalances.
+func testRebalanceError() error {
+	return &apiError{StatusCode: http.StatusConflict, ErrorCode: http.StatusConflict, Message: "rebalance in progress"}
+}
+
 func TestIsRebalanceError(t *testing.T) {
-	rebalanceErr := errors.New("rebalance in progress")
+	rebalanceErr := testRebalanceError()
 	if !isRebalanceError(rebalanceErr) {
 		t.Errorf("expected rebalance error to be detected")
 	}
@@ -165,6 +206,16 @@ func TestIsRebalanceError(t *testing.T) {
 	if isRebalanceError(normalErr) {
 		t.Errorf("expected normal error to not be detected as rebalance error")
 	}
+
+	namedErr := &apiError{StatusCode: http.StatusBadRequest, Message: "Connector orders-409 rebalance.config is invalid"}
+	if isRebalanceError(namedErr) {
+		t.Errorf("expected an error mentioning 409 and rebalance to not be detected as rebalance error")
+	}
+
+	existsErr := &apiError{StatusCode: http.StatusConflict, Message: "Connector orders already exists"}
+	if isRebalanceError(existsErr) {
+		t.Errorf("expected a conflicting connector name to not be detected as rebalance error")
+	}
 }
 
 func TestWithRebalanceRetry(t *testing.T) {
@@ -173,12 +224,12 @@ func TestWithRebalanceRetry(t *testing.T) {
 		operation := func() error {
 			callCount++
 			if callCount < 3 {
-				return errors.New("rebalance in progress")
+				return testRebalanceError()
 			}
 			return nil
 		}
 
-		err := withRebalanceRetry(operation, 5*time.Second)
+		err := withRetry(context.Background(), defaultRetryPolicy, operation, 5*time.Second)
 		if err != nil {
 			t.Errorf("expected no error, got: %v", err)
 		}
@@ -195,7 +246,7 @@ func TestWithRebalanceRetry(t *testing.T) {
 			return expectedErr
 		}
 
-		err := withRebalanceRetry(operation, 5*time.Second)
+		err := withRetry(context.Background(), defaultRetryPolicy, operation, 5*time.Second)
 		if err != expectedErr {
 			t.Errorf("expected error %v, got: %v", expectedErr, err)
 		}
@@ -209,11 +260,11 @@ func TestWithRebalanceRetry(t *testing.T) {
 		operation := func() error {
 			callCount++
 			// Always return rebalance error to force timeout
-			return errors.New("rebalance in progress")
+			return testRebalanceError()
 		}
 
 		start := time.Now()
-		err := withRebalanceRetry(operation, 1*time.Second)
+		err := withRetry(context.Background(), defaultRetryPolicy, operation, 1*time.Second)
 		duration := time.Since(start)
 
 		if err == nil {
@@ -238,12 +289,12 @@ func TestWithRebalanceRetry(t *testing.T) {
 		operation := func() error {
 			callCount++
 			if callCount < 5 {
-				return errors.New("rebalance in progress")
+				return testRebalanceError()
 			}
 			return nil
 		}
 
-		err := withRebalanceRetry(operation, 30*time.Second)
+		err := withRetry(context.Background(), defaultRetryPolicy, operation, 30*time.Second)
 		if err != nil {
 			t.Errorf("expected no error with long timeout, got: %v", err)
 		}
@@ -258,11 +309,11 @@ func TestWithRebalanceRetry(t *testing.T) {
 			callCount++
 			// Add small delay to ensure timeout is hit
 			time.Sleep(100 * time.Millisecond)
-			return errors.New("rebalance in progress")
+			return testRebalanceError()
 		}
 
 		start := time.Now()
-		err := withRebalanceRetry(operation, 50*time.Millisecond)
+		err := withRetry(context.Background(), defaultRetryPolicy, operation, 50*time.Millisecond)
 		duration := time.Since(start)
 
 		if err == nil {
@@ -277,3 +328,807 @@ func TestWithRebalanceRetry(t *testing.T) {
 		}
 	})
 }
+
+func TestConnectorReadNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error_code":404,"message":"Connector sqlite-sink not found"}`)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+		"name": "sqlite-sink",
+	})
+	d.SetId("sqlite-sink")
+
+	diags := connectorRead(context.Background(), d, newClient(srv.URL))
+	if diags.HasError() {
+		t.Fatalf("expected no error, got: %v", diags)
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("expected a single warning diagnostic, got: %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected ID to be cleared, got %q", d.Id())
+	}
+}
+
+func TestConnectorReadGatewayNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<html><body>404 Not Found</body></html>`)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+		"name": "sqlite-sink",
+	})
+	d.SetId("sqlite-sink")
+
+	diags := connectorRead(context.Background(), d, newClient(srv.URL))
+	if !diags.HasError() {
+		t.Errorf("expected a 404 without a Connect error body to fail the refresh, got: %v", diags)
+	}
+	if d.Id() != "sqlite-sink" {
+		t.Errorf("expected the connector to stay in state, got ID %q", d.Id())
+	}
+}
+
+func TestConnectorDeleteNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error_code":404,"message":"Connector sqlite-sink not found"}`)
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+		"name": "sqlite-sink",
+	})
+	d.SetId("sqlite-sink")
+
+	diags := connectorDelete(context.Background(), d, newClient(srv.URL))
+	if diags.HasError() {
+		t.Fatalf("expected delete of a missing connector to succeed, got: %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected ID to be cleared, got %q", d.Id())
+	}
+}
+
+func TestConnectorDeleteOffsetsOnDestroy(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStateRunning
+	tc.offsets["orders"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+
+	for _, name := range []string{"orders", "missing"} {
+		d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+			"name":                      name,
+			"delete_offsets_on_destroy": true,
+		})
+		d.SetId(name)
+		if diags := connectorDelete(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+			t.Fatalf("unexpected diagnostics deleting %s: %v", name, diags)
+		}
+	}
+
+	expected := "[PUT stop orders DELETE offsets orders DELETE orders]"
+	if got := fmt.Sprint(tc.recorded()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if tc.offsets["orders"] != "[]" {
+		t.Errorf("expected the offsets to be reset, got %s", tc.offsets["orders"])
+	}
+}
+
+func TestConnectorCreateInitialState(t *testing.T) {
+	create := func(t *testing.T, url string) (*schema.ResourceData, diag.Diagnostics) {
+		d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+			"name":          "orders",
+			"config":        map[string]interface{}{"tasks.max": "1"},
+			"initial_state": connectorStateStopped,
+		})
+		return d, connectorCreate(context.Background(), d, newClient(url))
+	}
+
+	t.Run("created stopped", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		d, diags := create(t, srv.URL)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		expected := "[POST STOPPED orders]"
+		if got := fmt.Sprint(tc.recorded()); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+		if got := d.Get("state").(string); got != connectorStateStopped {
+			t.Errorf("expected state STOPPED to be read back, got %s", got)
+		}
+	})
+
+	t.Run("old worker", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		tc.version = "7.5.3-ccs"
+		_, diags := create(t, srv.URL)
+		if !diags.HasError() || !diags[0].AttributePath.Equals(cty.GetAttrPath("initial_state")) ||
+			diags[0].Detail != "initial_state on create requires Kafka 3.7 or later, but the Connect worker runs 7.5.3-ccs" {
+			t.Errorf("expected a version error on initial_state, got %v", diags)
+		}
+		if got := tc.recorded(); len(got) != 0 {
+			t.Errorf("expected no connector to be created, got %v", got)
+		}
+	})
+}
+
+func TestConnectorCreateMovesPasswordKeys(t *testing.T) {
+	_, srv := newTestConnect(t)
+
+	// as when connection.url was unknown while planning
+	d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+		"name": "orders",
+		"config": map[string]interface{}{
+			"connector.class":     "jdbc",
+			"connection.url":      "jdbc:postgresql://db/orders",
+			"connection.password": "hunter2",
+		},
+	})
+	if diags := connectorCreate(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if _, ok := d.Get("config").(map[string]interface{})["connection.password"]; ok {
+		t.Errorf("expected connection.password to be kept out of config, got %v", d.Get("config"))
+	}
+	if v := d.Get("config_sensitive").(map[string]interface{})["connection.password"]; v != "hunter2" {
+		t.Errorf("expected connection.password in config_sensitive, got %v", v)
+	}
+}
+
+func TestConnectorCreateOffsets(t *testing.T) {
+	create := func(t *testing.T, url string) diag.Diagnostics {
+		d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+			"name":    "orders",
+			"config":  map[string]interface{}{"tasks.max": "1"},
+			"offsets": `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`,
+		})
+		return connectorCreate(context.Background(), d, newClient(url))
+	}
+
+	t.Run("seeded before running", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		if diags := create(t, srv.URL); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		expected := "[POST STOPPED orders PUT stop orders PATCH offsets orders PUT resume orders]"
+		if got := fmt.Sprint(tc.recorded()); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	})
+
+	t.Run("old worker", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		tc.version = "3.6.1"
+		diags := create(t, srv.URL)
+		if !diags.HasError() || !diags[0].AttributePath.Equals(cty.GetAttrPath("offsets")) {
+			t.Errorf("expected a version error on offsets, got %v", diags)
+		}
+		if got := tc.recorded(); len(got) != 0 {
+			t.Errorf("expected no connector to be created, got %v", got)
+		}
+	})
+}
+
+func TestConnectorPlanInitialState(t *testing.T) {
+	prior := testConnectorObject(map[string]cty.Value{
+		"id":   cty.StringVal("sqlite-sink"),
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"tasks.max": cty.StringVal("1"),
+		}),
+		"config_sensitive":           cty.MapValEmpty(cty.String),
+		"initial_state":              cty.StringVal(connectorStateStopped),
+		"wait_for_running":           cty.False,
+		"delete_offsets_on_destroy":  cty.False,
+		"preserve_offsets_on_rename": cty.False,
+		"detect_offsets_drift":       cty.False,
+	})
+	config := testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"tasks.max": cty.StringVal("1"),
+		}),
+		"initial_state": cty.StringVal(connectorStatePaused),
+	})
+
+	planned, diags := testPlanConnector(t, nil, prior, config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if v := planned.GetAttr("initial_state"); !v.RawEquals(cty.StringVal(connectorStateStopped)) {
+		t.Errorf("expected initial_state of an existing connector not to change, got %#v", v)
+	}
+}
+
+func TestConnectorUpdateConfigAndOffsets(t *testing.T) {
+	tc, srv := newTestConnect(t)
+	tc.states["orders"] = connectorStateRunning
+	tc.offsets["orders"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+
+	d := testConnectorUpdateData(t, map[string]interface{}{
+		"name":    "orders",
+		"config":  map[string]interface{}{"tasks.max": "1"},
+		"offsets": tc.offsets["orders"],
+	}, map[string]interface{}{
+		"name":    "orders",
+		"config":  map[string]interface{}{"tasks.max": "1", "topics": "orders,refunds"},
+		"offsets": `[{"partition":{"filename":"a.txt"},"offset":{"position":0}}]`,
+	})
+
+	if diags := updateConnectorInPlace(context.Background(), newClient(srv.URL), d); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	expected := "[PUT stop orders PUT config orders PUT stop orders PATCH offsets orders PUT resume orders]"
+	if got := fmt.Sprint(tc.recorded()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestRenameConnector(t *testing.T) {
+	// renameData applies a change of name on top of a connector in state
+	renameData := func(t *testing.T, declared string) *schema.ResourceData {
+		prior := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+			"name":    "orders-v1",
+			"config":  map[string]interface{}{"tasks.max": "1"},
+			"state":   connectorStatePaused,
+			"offsets": declared,
+		})
+		prior.SetId("orders-v1")
+		d := kafkaConnectorResource().Data(prior.State())
+		d.Set("name", "orders-v2")
+		return d
+	}
+	rename := func(t *testing.T, url string, declared string) error {
+		return renameConnector(context.Background(), newClient(url), renameData(t, declared))
+	}
+
+	t.Run("moves offsets", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		tc.states["orders-v1"] = connectorStateRunning
+		tc.offsets["orders-v1"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}},{"partition":{"filename":"b.txt"},"offset":{"position":7}}]`
+
+		if err := rename(t, srv.URL, `[{"partition":{"filename":"b.txt"},"offset":{"position":1}}]`); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "[PUT stop orders-v1 POST STOPPED orders-v2 PUT stop orders-v2 PATCH offsets orders-v2 PUT pause orders-v2 DELETE orders-v1]"
+		if got := fmt.Sprint(tc.recorded()); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+		expected = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}},{"partition":{"filename":"b.txt"},"offset":{"position":1}}]`
+		if tc.offsets["orders-v2"] != expected {
+			t.Errorf("expected offsets %s, got %s", expected, tc.offsets["orders-v2"])
+		}
+	})
+
+	t.Run("rolls back", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		tc.states["orders-v1"] = connectorStatePaused
+		tc.offsets["orders-v1"] = `[{"partition":{"filename":"a.txt"},"offset":{"position":42}}]`
+		tc.fail = "PATCH offsets orders-v2"
+
+		d := renameData(t, "")
+		if err := renameConnector(context.Background(), newClient(srv.URL), d); err == nil {
+			t.Fatal("expected the rename to fail")
+		}
+
+		expected := "[PUT stop orders-v1 POST STOPPED orders-v2 PUT stop orders-v2 DELETE orders-v2 PUT pause orders-v1]"
+		if got := fmt.Sprint(tc.recorded()); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+		if state := d.State(); state.ID != "orders-v1" || state.Attributes["name"] != "orders-v1" {
+			t.Errorf("expected state to keep tracking the old connector, got %s %s", state.ID, state.Attributes["name"])
+		}
+	})
+
+	t.Run("old worker", func(t *testing.T) {
+		tc, srv := newTestConnect(t)
+		tc.version = "3.6.2"
+		tc.states["orders-v1"] = connectorStateRunning
+
+		err := rename(t, srv.URL, "")
+		if err == nil || !strings.Contains(err.Error(), "requires Kafka 3.7") {
+			t.Errorf("expected a version error, got %v", err)
+		}
+		if got := tc.recorded(); len(got) != 0 {
+			t.Errorf("expected no changes, got %v", got)
+		}
+	})
+}
+
+func TestWaitForConnectorRunning(t *testing.T) {
+	// serve answers the nth poll of the connector and its status
+	serve := func(t *testing.T, respond func(poll int) (info string, status string)) (*httptest.Server, *int) {
+		polls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if !strings.HasSuffix(req.URL.Path, "/status") {
+				polls++
+			}
+			info, status := respond(polls)
+			if strings.HasSuffix(req.URL.Path, "/status") {
+				fmt.Fprint(w, status)
+				return
+			}
+			fmt.Fprint(w, info)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &polls
+	}
+	config := map[string]interface{}{"tasks.max": "1"}
+	wait := func(url string, name string) diag.Diagnostics {
+		return waitForConnectorRunning(context.Background(), newClient(url), name, config, nil, 10*time.Second)
+	}
+
+	t.Run("tasks become running", func(t *testing.T) {
+		srv, polls := serve(t, func(poll int) (string, string) {
+			info := `{"name":"sqlite-sink","config":{"tasks.max":"1"},"tasks":[{"connector":"sqlite-sink","task":0}]}`
+			if poll < 2 {
+				return info, `{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"UNASSIGNED","worker_id":"w1"}]}`
+			}
+			return info, `{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"w1"}]}`
+		})
+
+		if diags := wait(srv.URL, "sqlite-sink"); diags.HasError() {
+			t.Fatalf("expected no error, got: %v", diags)
+		}
+		if *polls != 2 {
+			t.Errorf("expected 2 polls, got %d", *polls)
+		}
+	})
+
+	t.Run("tasks not yet started", func(t *testing.T) {
+		srv, polls := serve(t, func(poll int) (string, string) {
+			info := `{"name":"jdbc-source","config":{"tasks.max":"1"},"tasks":[{"connector":"jdbc-source","task":0}]}`
+			if poll < 2 {
+				return info, `{"name":"jdbc-source","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[]}`
+			}
+			return info, `{"name":"jdbc-source","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"w1"}]}`
+		})
+
+		if diags := wait(srv.URL, "jdbc-source"); diags.HasError() {
+			t.Fatalf("expected no error, got: %v", diags)
+		}
+		if *polls != 2 {
+			t.Errorf("expected the wait to last until the listed task runs, got %d polls", *polls)
+		}
+	})
+
+	t.Run("no tasks", func(t *testing.T) {
+		srv, polls := serve(t, func(poll int) (string, string) {
+			return `{"name":"jdbc-source","config":{"tasks.max":"1"},"tasks":[]}`,
+				`{"name":"jdbc-source","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[]}`
+		})
+
+		if diags := wait(srv.URL, "jdbc-source"); diags.HasError() {
+			t.Fatalf("expected a connector without tasks to count as running, got: %v", diags)
+		}
+		if *polls != 1 {
+			t.Errorf("expected 1 poll, got %d", *polls)
+		}
+	})
+
+	t.Run("previous config", func(t *testing.T) {
+		srv, polls := serve(t, func(poll int) (string, string) {
+			if poll < 2 {
+				return `{"name":"sqlite-sink","config":{"tasks.max":"2"},"tasks":[{"connector":"sqlite-sink","task":0}]}`,
+					`{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"w1"}]}`
+			}
+			return `{"name":"sqlite-sink","config":{"tasks.max":"1"},"tasks":[{"connector":"sqlite-sink","task":0}]}`,
+				`{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"w1"}]}`
+		})
+
+		if diags := wait(srv.URL, "sqlite-sink"); diags.HasError() {
+			t.Fatalf("expected no error, got: %v", diags)
+		}
+		if *polls != 2 {
+			t.Errorf("expected the tasks of the previous config to be ignored, got %d polls", *polls)
+		}
+	})
+
+	t.Run("failed task reports its trace", func(t *testing.T) {
+		srv, _ := serve(t, func(poll int) (string, string) {
+			return `{"name":"sqlite-sink","config":{"tasks.max":"1"},"tasks":[{"connector":"sqlite-sink","task":0}]}`,
+				`{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1"},"tasks":[{"id":0,"state":"FAILED","worker_id":"w1","trace":"java.sql.SQLException: boom"}]}`
+		})
+
+		diags := wait(srv.URL, "sqlite-sink")
+		if !diags.HasError() {
+			t.Fatalf("expected an error diagnostic")
+		}
+		if diags[0].Detail != "java.sql.SQLException: boom" {
+			t.Errorf("expected the task trace in the diagnostic detail, got %q", diags[0].Detail)
+		}
+	})
+}
+
+func TestConnectorReadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/connectors/sqlite-sink":
+			fmt.Fprint(w, `{"name":"sqlite-sink","config":{"name":"sqlite-sink","tasks.max":"1"},"tasks":[{"connector":"sqlite-sink","task":0}],"type":"sink"}`)
+		case "/connectors/sqlite-sink/status":
+			fmt.Fprint(w, `{"name":"sqlite-sink","connector":{"state":"RUNNING","worker_id":"w1:8083"},"tasks":[{"id":0,"state":"FAILED","worker_id":"w2:8083","trace":"boom"}],"type":"sink"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	d := schema.TestResourceDataRaw(t, kafkaConnectorResource().Schema, map[string]interface{}{
+		"name": "sqlite-sink",
+	})
+	d.SetId("sqlite-sink")
+
+	if diags := connectorRead(context.Background(), d, newClient(srv.URL)); diags.HasError() {
+		t.Fatalf("expected no error, got: %v", diags)
+	}
+
+	expected := map[string]interface{}{
+		"state":             connectorStateRunning,
+		"connector_state":   "RUNNING",
+		"worker_id":         "w1:8083",
+		"type":              "sink",
+		"tasks.#":           1,
+		"tasks.0.id":        0,
+		"tasks.0.state":     "FAILED",
+		"tasks.0.worker_id": "w2:8083",
+		"tasks.0.trace":     "boom",
+	}
+	for k, v := range expected {
+		if got := d.Get(k); got != v {
+			t.Errorf("expected %s to be %v, got %v", k, v, got)
+		}
+	}
+}
+
+func TestConfigValidationErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut || req.URL.Path != "/connector-plugins/io.confluent.connect.jdbc.JdbcSinkConnector/config/validate" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"name": "io.confluent.connect.jdbc.JdbcSinkConnector",
+			"error_count": 2,
+			"configs": [
+				{"definition": {"name": "tasks.max", "type": "INT"}, "value": {"name": "tasks.max", "value": "1", "errors": []}},
+				{"definition": {"name": "connection.url", "type": "STRING"}, "value": {"name": "connection.url", "value": "jdbc:nope", "errors": ["No suitable driver found for jdbc:nope"]}},
+				{"definition": {"name": "connection.password", "type": "PASSWORD"}, "value": {"name": "connection.password", "value": "[hidden]", "errors": ["Access denied for password hunter2"]}}
+			]
+		}`)
+	}))
+	defer srv.Close()
+
+	config := map[string]interface{}{
+		"connector.class":     "io.confluent.connect.jdbc.JdbcSinkConnector",
+		"connection.url":      "jdbc:nope",
+		"connection.password": "hunter2",
+	}
+	sensitive := map[string]interface{}{"connection.password": "hunter2"}
+
+	result, err := newClient(srv.URL).validateConnectorConfig(context.Background(), "io.confluent.connect.jdbc.JdbcSinkConnector", config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	err = configValidationErrors(result, config, sensitive)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("expected sensitive value to be redacted, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "No suitable driver") {
+		t.Errorf("expected the connection.url error to be reported, got: %v", err)
+	}
+
+	result.Configs = result.Configs[:2]
+	err = configValidationErrors(result, config, sensitive)
+	var pathErr cty.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a single error to be scoped to its attribute, got: %#v", err)
+	}
+	expected := cty.GetAttrPath("config").Index(cty.StringVal("connection.url"))
+	if !pathErr.Path.Equals(expected) {
+		t.Errorf("expected path %#v, got %#v", expected, pathErr.Path)
+	}
+}
+
+func TestConnectorPlanMovesPasswordKeys(t *testing.T) {
+	validations := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		validations++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"name": "io.confluent.connect.jdbc.JdbcSinkConnector",
+			"error_count": 0,
+			"configs": [
+				{"definition": {"name": "connection.user", "type": "STRING"}, "value": {"name": "connection.user", "errors": []}},
+				{"definition": {"name": "connection.password", "type": "PASSWORD"}, "value": {"name": "connection.password", "errors": []}}
+			]
+		}`)
+	}))
+	defer srv.Close()
+
+	config := testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"name":                cty.StringVal("sqlite-sink"),
+			"connector.class":     cty.StringVal("io.confluent.connect.jdbc.JdbcSinkConnector"),
+			"connection.user":     cty.StringVal("admin"),
+			"connection.password": cty.StringVal("hunter2"),
+		}),
+	})
+
+	planned, diags := testPlanConnector(t, newClient(srv.URL), testConnectorObject(nil), config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if planned.GetAttr("config").HasIndex(cty.StringVal("connection.password")).True() {
+		t.Errorf("expected connection.password to be moved out of config, got %#v", planned.GetAttr("config"))
+	}
+	if v := planned.GetAttr("config_sensitive").Index(cty.StringVal("connection.password")); !v.RawEquals(cty.StringVal("hunter2")) {
+		t.Errorf("expected connection.password in config_sensitive, got %#v", v)
+	}
+
+	// once applied, the next plan must be empty and not need to validate
+	prior := testConnectorObject(map[string]cty.Value{
+		"id":               cty.StringVal("sqlite-sink"),
+		"name":             cty.StringVal("sqlite-sink"),
+		"config":           planned.GetAttr("config"),
+		"config_sensitive": planned.GetAttr("config_sensitive"),
+		"wait_for_running": cty.False,
+	})
+	validations = 0
+	planned, diags = testPlanConnector(t, newClient(srv.URL), prior, config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if !planned.GetAttr("config").RawEquals(prior.GetAttr("config")) ||
+		!planned.GetAttr("config_sensitive").RawEquals(prior.GetAttr("config_sensitive")) {
+		t.Errorf("expected an empty plan, got config %#v", planned.GetAttr("config"))
+	}
+	if validations != 0 {
+		t.Errorf("expected no validation of an unchanged config, got %d", validations)
+	}
+}
+
+// testConnectorObject returns a connector resource object with the given
+// attributes and every other attribute null.
+func testConnectorObject(attrs map[string]cty.Value) cty.Value {
+	ty := kafkaConnectorResource().CoreConfigSchema().ImpliedType()
+	vals := make(map[string]cty.Value)
+	for k, at := range ty.AttributeTypes() {
+		vals[k] = cty.NullVal(at)
+	}
+	for k, v := range attrs {
+		vals[k] = v
+	}
+	return cty.ObjectVal(vals)
+}
+
+// testPlanConnector plans a connector through the gRPC provider server, so
+// that CustomizeDiff sees the raw config the same way it does under Terraform.
+// testConnectorUpdateData returns the data of an update from the prior
+// config of a connector to the new one.
+func testConnectorUpdateData(t *testing.T, prior map[string]interface{}, config map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+	r := kafkaConnectorResource()
+	p := schema.TestResourceDataRaw(t, r.Schema, prior)
+	p.SetId(p.Get("name").(string))
+	state := p.State()
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func testPlanConnector(t *testing.T, meta interface{}, prior cty.Value, config cty.Value) (cty.Value, []*tfprotov5.Diagnostic) {
+	t.Helper()
+
+	resp := testPlanConnectorChange(t, meta, prior, config)
+	ty := kafkaConnectorResource().CoreConfigSchema().ImpliedType()
+	if resp.PlannedState == nil {
+		return cty.NullVal(ty), resp.Diagnostics
+	}
+
+	planned, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, ty)
+	if err != nil {
+		t.Fatalf("failed to decode the plan: %v", err)
+	}
+	return planned, resp.Diagnostics
+}
+
+// testPlanConnectorChange returns the raw plan response, including the
+// attributes that require replacement.
+func testPlanConnectorChange(t *testing.T, meta interface{}, prior cty.Value, config cty.Value) *tfprotov5.PlanResourceChangeResponse {
+	t.Helper()
+
+	p := Provider()
+	p.SetMeta(meta)
+	ty := p.ResourcesMap["kafka-connect_connector"].CoreConfigSchema().ImpliedType()
+	if !prior.IsKnown() || prior.GetAttr("id").IsNull() {
+		prior = cty.NullVal(ty)
+	}
+
+	encode := func(v cty.Value) *tfprotov5.DynamicValue {
+		b, err := msgpack.Marshal(v, ty)
+		if err != nil {
+			t.Fatalf("failed to encode %#v: %v", v, err)
+		}
+		return &tfprotov5.DynamicValue{MsgPack: b}
+	}
+
+	resp, err := schema.NewGRPCProviderServer(p).PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+		TypeName:         "kafka-connect_connector",
+		PriorState:       encode(prior),
+		ProposedNewState: encode(config),
+		Config:           encode(config),
+	})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	return resp
+}
+
+func TestConnectorPlanRename(t *testing.T) {
+	prior := testConnectorObject(map[string]cty.Value{
+		"id":   cty.StringVal("sqlite-sink"),
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"tasks.max": cty.StringVal("1"),
+		}),
+		"config_sensitive":           cty.MapValEmpty(cty.String),
+		"wait_for_running":           cty.False,
+		"delete_offsets_on_destroy":  cty.False,
+		"preserve_offsets_on_rename": cty.False,
+	})
+
+	for _, preserve := range []bool{false, true} {
+		config := testConnectorObject(map[string]cty.Value{
+			"name": cty.StringVal("orders-sink"),
+			"config": cty.MapVal(map[string]cty.Value{
+				"tasks.max": cty.StringVal("1"),
+			}),
+			"preserve_offsets_on_rename": cty.BoolVal(preserve),
+		})
+		resp := testPlanConnectorChange(t, nil, prior, config)
+		if len(resp.Diagnostics) > 0 {
+			t.Fatalf("expected no diagnostics, got: %v", resp.Diagnostics)
+		}
+		if replace := len(resp.RequiresReplace) > 0; replace == preserve {
+			t.Errorf("preserve_offsets_on_rename = %t: expected replacement %t, got %v", preserve, !preserve, resp.RequiresReplace)
+		}
+	}
+}
+
+func TestConnectorPlanWriteOnlyConfig(t *testing.T) {
+	config := testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"name": cty.StringVal("sqlite-sink"),
+		}),
+		"config_sensitive_wo":         cty.StringVal(`{"connection.password":"hunter2"}`),
+		"config_sensitive_wo_version": cty.StringVal("1"),
+	})
+
+	planned, diags := testPlanConnector(t, nil, testConnectorObject(nil), config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if !planned.GetAttr("config_sensitive_wo").IsNull() {
+		t.Errorf("expected the write-only value to be left out of the plan")
+	}
+	expected := cty.SetVal([]cty.Value{cty.StringVal("connection.password")})
+	if keys := planned.GetAttr("config_sensitive_wo_keys"); !keys.RawEquals(expected) {
+		t.Errorf("expected config_sensitive_wo_keys %#v, got %#v", expected, keys)
+	}
+}
+
+func TestRefreshSensitiveConfig(t *testing.T) {
+	cached := map[string]interface{}{
+		"connection.password": "hunter2",
+		"rotated.password":    "hunter2",
+		"hidden.password":     "hunter2",
+		"provided.password":   "hunter2",
+		"removed.password":    "hunter2",
+	}
+	remote := map[string]interface{}{
+		"connection.password": "hunter2",
+		"rotated.password":    "correct-horse",
+		"hidden.password":     "[hidden]",
+		"provided.password":   "${file:/opt/secrets.properties:password}",
+	}
+
+	refreshed := refreshSensitiveConfig(remote, cached)
+
+	for _, k := range []string{"connection.password", "hidden.password", "provided.password"} {
+		if refreshed[k] != "hunter2" {
+			t.Errorf("expected %s to keep its cached value, got %v", k, refreshed[k])
+		}
+	}
+	if refreshed["rotated.password"] != sensitiveValueHash("correct-horse") {
+		t.Errorf("expected rotated.password to be replaced by the remote hash, got %v", refreshed["rotated.password"])
+	}
+	if _, ok := refreshed["removed.password"]; ok {
+		t.Errorf("expected removed.password to be dropped")
+	}
+
+	// a drifted value stays stable across refreshes until it is reapplied
+	if again := refreshSensitiveConfig(remote, refreshed); again["rotated.password"] != refreshed["rotated.password"] {
+		t.Errorf("expected the drift marker to be stable, got %v", again["rotated.password"])
+	}
+}
+
+func TestKafkaConnectorStateUpgradeV0(t *testing.T) {
+	rawState := map[string]interface{}{
+		"id":   "sqlite-sink",
+		"name": "sqlite-sink",
+		"config": map[string]interface{}{
+			"name":      "sqlite-sink",
+			"tasks.max": "1",
+		},
+	}
+
+	upgraded, err := kafkaConnectorStateUpgradeV0(context.Background(), rawState, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	config := upgraded["config"].(map[string]interface{})
+	if _, ok := config["name"]; ok {
+		t.Errorf("expected config.name to be removed, got %v", config)
+	}
+	if config["tasks.max"] != "1" {
+		t.Errorf("expected other config keys to be kept, got %v", config)
+	}
+}
+
+func TestConnectorPlanConfigName(t *testing.T) {
+	config := testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"name":      cty.StringVal("sqlite-sink"),
+			"tasks.max": cty.StringVal("1"),
+		}),
+	})
+	planned, diags := testPlanConnector(t, nil, testConnectorObject(nil), config)
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+	if planned.GetAttr("config").HasIndex(cty.StringVal("name")).True() {
+		t.Errorf("expected config.name matching the resource name to be left out of the plan")
+	}
+
+	config = testConnectorObject(map[string]cty.Value{
+		"name": cty.StringVal("sqlite-sink"),
+		"config": cty.MapVal(map[string]cty.Value{
+			"name": cty.StringVal("other-sink"),
+		}),
+	})
+	_, diags = testPlanConnector(t, nil, testConnectorObject(nil), config)
+	if len(diags) != 1 || diags[0].Attribute == nil {
+		t.Fatalf("expected a single attribute-scoped error, got: %v", diags)
+	}
+}
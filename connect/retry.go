@@ -0,0 +1,200 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// The conditions a retry policy can retry on.
+const (
+	retryOnRebalance         = "rebalance"
+	retryOnServerError       = "server_error"
+	retryOnConnectionRefused = "connection_refused"
+	retryOnConnectionReset   = "connection_reset"
+	retryOnTimeout           = "timeout"
+)
+
+var retryConditions = []string{
+	retryOnRebalance,
+	retryOnServerError,
+	retryOnConnectionRefused,
+	retryOnConnectionReset,
+	retryOnTimeout,
+}
+
+// retryPolicy controls how failed Connect requests are retried. Requests are
+// retried with exponential backoff until the operation timeout expires.
+type retryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the largest random fraction of the backoff added to it.
+	Jitter  float64
+	RetryOn map[string]bool
+}
+
+// defaultRetryPolicy only waits out rebalances.
+var defaultRetryPolicy = retryPolicy{
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Jitter:         0.5,
+	RetryOn:        map[string]bool{retryOnRebalance: true},
+}
+
+// retryable reports whether the policy retries the error.
+func (p retryPolicy) retryable(err error) bool {
+	return p.RetryOn[retryCondition(err)]
+}
+
+// retryCondition returns the retry condition the error falls under, or ""
+// if it is not one of them.
+func retryCondition(err error) string {
+	var apiErr *apiError
+	var netErr net.Error
+	switch {
+	case isRebalanceError(err):
+		return retryOnRebalance
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return retryOnServerError
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return retryOnConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return retryOnConnectionReset
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return retryOnTimeout
+	default:
+		return ""
+	}
+}
+
+// withRetry executes the provided function with exponential backoff retry
+// logic, retrying the errors the policy allows, such as those seen while
+// Kafka Connect rebalances. The timeout parameter specifies how long to keep
+// retrying; cancelling the context stops at once.
+func withRetry(ctx context.Context, policy retryPolicy, fn func() error, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := policy.InitialBackoff
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !policy.retryable(err) {
+			return err
+		}
+		condition := retryCondition(err)
+		if time.Now().After(deadline) {
+			if condition == retryOnRebalance {
+				return fmt.Errorf("timed out waiting for Kafka Connect rebalance to finish: %w", err)
+			}
+			return fmt.Errorf("timed out retrying Kafka Connect request: %w", err)
+		}
+		sleep := backoff
+		if jitter := int64(float64(backoff) * policy.Jitter); jitter > 0 {
+			sleep += time.Duration(rand.Int63n(jitter))
+		}
+		log.Printf("[INFO] Retrying Kafka Connect request on %s after %.2fs ... (%v)", condition, sleep.Seconds(), err)
+		if cerr := sleepContext(ctx, sleep); cerr != nil {
+			return fmt.Errorf("%w while retrying Kafka Connect request: %w", cerr, err)
+		}
+		if backoff < policy.MaxBackoff {
+			backoff *= 2
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
+
+// retryPolicyFromRD returns the policy of the retry block, falling back to
+// defaultRetryPolicy for anything not set.
+func retryPolicyFromRD(d *schema.ResourceData) (retryPolicy, error) {
+	policy := defaultRetryPolicy
+	v, ok := d.GetOk("retry")
+	if !ok || v.([]interface{})[0] == nil {
+		return policy, nil
+	}
+	block := v.([]interface{})[0].(map[string]interface{})
+
+	policy.InitialBackoff, _ = time.ParseDuration(block["initial_backoff"].(string))
+	policy.MaxBackoff, _ = time.ParseDuration(block["max_backoff"].(string))
+	if policy.InitialBackoff > policy.MaxBackoff {
+		return policy, fmt.Errorf("initial_backoff %s is longer than max_backoff %s", policy.InitialBackoff, policy.MaxBackoff)
+	}
+	policy.Jitter = block["jitter"].(float64)
+
+	if on := block["retry_on"].(*schema.Set).List(); len(on) > 0 {
+		policy.RetryOn = map[string]bool{}
+		for _, condition := range on {
+			policy.RetryOn[condition.(string)] = true
+		}
+	}
+	return policy, nil
+}
+
+func retrySchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "How failed requests to Kafka Connect are retried, until the timeout of the operation expires.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"initial_backoff": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      defaultRetryPolicy.InitialBackoff.String(),
+					ValidateFunc: validateDuration,
+					Description:  "Wait before the first retry, doubled after each retry.",
+				},
+				"max_backoff": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      defaultRetryPolicy.MaxBackoff.String(),
+					ValidateFunc: validateDuration,
+					Description:  "Longest wait between two retries.",
+				},
+				"jitter": {
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      defaultRetryPolicy.Jitter,
+					ValidateFunc: validation.FloatBetween(0, 1),
+					Description:  "Largest random fraction of the wait added to it, between 0 and 1.",
+				},
+				"retry_on": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringInSlice(retryConditions, false),
+					},
+					Description: "Conditions to retry on: rebalance, server_error, connection_refused, connection_reset and timeout. Defaults to rebalance.",
+				},
+			},
+		},
+	}
+}
+
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	d, err := time.ParseDuration(v.(string))
+	if err != nil {
+		return nil, []error{fmt.Errorf("%s must be a duration such as 250ms or 5s: %v", k, err)}
+	}
+	if d <= 0 {
+		return nil, []error{fmt.Errorf("%s must be positive", k)}
+	}
+	return nil, nil
+}
@@ -0,0 +1,130 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestRetryCondition(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, refused := newClient(closed.URL).serverInfo(context.Background())
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	_, serverErr := newClient(unavailable.URL).serverInfo(context.Background())
+
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer hung.Close()
+	c := newClient(hung.URL)
+	c.rest.SetTimeout(50 * time.Millisecond)
+	_, timeout := c.serverInfo(context.Background())
+
+	cases := map[string]struct {
+		err      error
+		expected string
+	}{
+		"rebalance":          {testRebalanceError(), retryOnRebalance},
+		"server error":       {serverErr, retryOnServerError},
+		"connection refused": {refused, retryOnConnectionRefused},
+		"timeout":            {timeout, retryOnTimeout},
+		"other":              {errors.New("connection timeout"), ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := retryCondition(tc.err); got != tc.expected {
+				t.Errorf("expected %q for %v, got %q", tc.expected, tc.err, got)
+			}
+		})
+	}
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	serverErr := &apiError{StatusCode: http.StatusBadGateway, Message: "bad gateway", action: "get connector"}
+	policy := retryPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		RetryOn:        map[string]bool{retryOnServerError: true},
+	}
+
+	calls := 0
+	err := withRetry(context.Background(), policy, func() error {
+		calls++
+		if calls < 3 {
+			return serverErr
+		}
+		return nil
+	}, 5*time.Second)
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = withRetry(context.Background(), defaultRetryPolicy, func() error {
+		calls++
+		return serverErr
+	}, 5*time.Second)
+	if err != serverErr || calls != 1 {
+		t.Errorf("expected the default policy not to retry server errors, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPolicyFromRD(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"retry": []interface{}{map[string]interface{}{
+			"initial_backoff": "1s",
+			"max_backoff":     "30s",
+			"jitter":          0.2,
+			"retry_on":        []interface{}{"server_error", "connection_refused"},
+		}},
+	})
+	policy, err := retryPolicyFromRD(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.InitialBackoff != time.Second || policy.MaxBackoff != 30*time.Second || policy.Jitter != 0.2 {
+		t.Errorf("unexpected backoff in %+v", policy)
+	}
+	if policy.RetryOn[retryOnRebalance] || !policy.RetryOn[retryOnServerError] || !policy.RetryOn[retryOnConnectionRefused] {
+		t.Errorf("unexpected conditions in %+v", policy.RetryOn)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"retry": []interface{}{map[string]interface{}{
+			"initial_backoff": "10s",
+			"max_backoff":     "1s",
+		}},
+	})
+	if _, err := retryPolicyFromRD(d); err == nil {
+		t.Error("expected an error for initial_backoff longer than max_backoff")
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := withRetry(ctx, defaultRetryPolicy, func() error {
+		return testRebalanceError()
+	}, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the retry to be cancelled, got %v", err)
+	}
+	if !isRebalanceError(err) {
+		t.Errorf("expected the last error to be kept, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the retry to stop soon after cancellation, took %v", elapsed)
+	}
+}
@@ -0,0 +1,52 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// kafkaVersion returns the Apache Kafka major and minor version of a Connect
+// worker version. Confluent Platform builds, such as 7.6.0-ccs, are mapped to
+// the Kafka release they ship: 7.x to 3.x and 8.x to 4.x.
+func kafkaVersion(version string) (int, int, bool) {
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+
+	if strings.Contains(version, "-ccs") || strings.Contains(version, "-ce") {
+		if major < 7 {
+			return 0, 0, false
+		}
+		major -= 4
+	}
+	return major, minor, true
+}
+
+// requireConnectVersion fails with a clear error when the worker runs a
+// Kafka version older than major.minor. Versions that cannot be parsed are
+// let through, as the request itself will fail against an older worker.
+func requireConnectVersion(ctx context.Context, c *client, feature string, major int, minor int) error {
+	info, err := c.serverInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	gotMajor, gotMinor, ok := kafkaVersion(info.Version)
+	if !ok {
+		log.Printf("[WARN] Unable to parse Kafka Connect version %q, assuming %s is supported", info.Version, feature)
+		return nil
+	}
+	if gotMajor < major || (gotMajor == major && gotMinor < minor) {
+		return fmt.Errorf("%s requires Kafka %d.%d or later, but the Connect worker runs %s", feature, major, minor, info.Version)
+	}
+	return nil
+}
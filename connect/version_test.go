@@ -0,0 +1,51 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKafkaVersion(t *testing.T) {
+	cases := map[string]string{
+		"3.7.0":          "3.7",
+		"3.6.1":          "3.6",
+		"4.0.0":          "4.0",
+		"7.6.0-ccs":      "3.6",
+		"7.7.1-ce":       "3.7",
+		"8.0.0-ccs":      "4.0",
+		"3.9.0-SNAPSHOT": "3.9",
+		"unknown":        "",
+		"6.2.0-ccs":      "",
+	}
+	for version, expected := range cases {
+		major, minor, ok := kafkaVersion(version)
+		got := ""
+		if ok {
+			got = fmt.Sprintf("%d.%d", major, minor)
+		}
+		if got != expected {
+			t.Errorf("%s: expected %q, got %q", version, expected, got)
+		}
+	}
+}
+
+func TestRequireConnectVersion(t *testing.T) {
+	for version, expectErr := range map[string]bool{"3.6.1": true, "3.7.0": false, "7.7.0-ccs": false, "dev": false} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"version":%q,"commit":"abc","kafka_cluster_id":"xyz"}`, version)
+		}))
+		err := requireConnectVersion(context.Background(), newClient(srv.URL), "initial_state", 3, 7)
+		srv.Close()
+
+		if expectErr && (err == nil || err.Error() != "initial_state requires Kafka 3.7 or later, but the Connect worker runs "+version) {
+			t.Errorf("%s: expected a version error, got %v", version, err)
+		}
+		if !expectErr && err != nil {
+			t.Errorf("%s: unexpected error %v", version, err)
+		}
+	}
+}
@@ -6,7 +6,6 @@ resource "kafka-connect_connector" "sqlite-sink" {
   name = "sqlite-sink"
 
   config = {
-    "name"            = "sqlite-sink"
     "connector.class" = "io.confluent.connect.jdbc.JdbcSinkConnector"
     "tasks.max"       = 1
     "topics"          = "orders"
@@ -32,7 +31,6 @@ resource "kafka-connect_connector" "sqlite-sink-with-auth" {
   name = "sqlite-sink-with-auth"
 
   config = {
-    "name"            = "sqlite-sink-with-auth"
     "connector.class" = "io.confluent.connect.jdbc.JdbcSinkConnector"
     "tasks.max"       = 1
     "topics"          = "orders"